@@ -0,0 +1,59 @@
+// Package acl keeps access control lists for files: who may own,
+// read, write and execute each one, directly or through roles (see
+// roles.go), and inherited from the directories above them (see
+// inherit.go). Lists are read from and written to a few formats (see
+// parse.go, export.go, yaml.go, write.go and facl.go), changed by
+// command files (see commands.go), checked (see check.go), reported on
+// across every list at once (see report.go), and looked over for likely
+// mistakes (see lint.go). The users they name can be checked against
+// /etc/passwd and /etc/group (see directory.go), and files can be
+// signed, so changes made without the key are caught (see sign.go).
+//
+// A Store (see service.go) holds the lists for any number of files and
+// is safe to use from many goroutines at once. Handler (see server.go)
+// serves one over HTTP, and Shell (see shell.go) edits one a line at a
+// time. Lists with tens of thousands of entries are fine, they're
+// indexed by user (see entries.go).
+package acl
+
+import (
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Where messages about what's going on are printed, as files are read
+// and commands are run, by stores that haven't been given somewhere
+// else to print them (see Store.SetMessages)
+var defaultMsgs = struct {
+	sync.RWMutex
+	w io.Writer
+}{w: os.Stdout}
+
+// Print messages about what's going on to w instead of stdout. Use
+// ioutil.Discard to not print them at all.
+func SetMessages(w io.Writer) {
+	defaultMsgs.Lock()
+	defer defaultMsgs.Unlock()
+	defaultMsgs.w = w
+}
+
+// Where messages go when they aren't a store's
+func messages() io.Writer {
+	defaultMsgs.RLock()
+	defer defaultMsgs.RUnlock()
+	return defaultMsgs.w
+}
+
+// Check and purge time limited entries as of t instead of the current
+// time, see expiry.go
+func SetNow(t time.Time) {
+	setClock(func() time.Time { return t })
+}
+
+// Parse a time the way ACL files take them, like 2015-12-31 or
+// 2015-12-31T17:00:00Z
+func ParseTime(str string) (t time.Time, err error) {
+	return parseTimeLimit(str)
+}
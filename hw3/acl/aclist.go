@@ -0,0 +1,325 @@
+package acl
+
+import (
+	"fmt"
+	"time"
+)
+
+// type alias for rights, one bit each. Only the four below are built
+// in, more can be defined in a rights file, see rights.go
+type right uint32
+
+// Constant for rights values
+const (
+	R_EXEC right = 1 << iota
+	R_WRITE
+	R_READ
+	R_OWN
+)
+
+// Each ACE has the username and associated rights. A deny entry
+// takes its rights away instead of granting them, see check.go.
+// An entry may also only count from, or until, a certain time (zero
+// times mean no limit), see expiry.go, and hold roles that bring
+// rights of their own, see roles.go.
+type accessControlEntry struct {
+	user      string
+	rights    right
+	deny      bool
+	notBefore time.Time
+	expires   time.Time
+	roles     []string
+}
+
+// Each ACL has a filename and its ACEs, in order, see entries.go for
+// how they're kept. Group entries are resolved through the groups of
+// the store the list belongs to. A list may block inheriting from the
+// directories above it, see inherit.go.
+type accessControlList struct {
+	filename  string
+	ace       *entryList
+	groups    *groupTable
+	noInherit bool
+}
+
+// var maxEntries int = 100
+
+func (acl *accessControlList) initialize(filename string) (ok bool) {
+	// set filename
+	acl.filename = filename
+	// make ace list, empty for now.
+	acl.ace = newEntryList()
+
+	return true
+}
+
+// The list's entries, made if the list doesn't have any yet, for
+// adding to
+func (acl *accessControlList) entries() *entryList {
+	if acl.ace == nil {
+		acl.ace = newEntryList()
+	}
+	return acl.ace
+}
+
+func (acl *accessControlList) addEntry(newUser string, rights right) (ok bool) {
+	// Check for duplicate usernames
+	if acl.ace.indexOf(newUser, false) >= 0 {
+		return false
+	}
+
+	// Check if the new user is an owner, if they are, add to the front
+	// and return.
+	if (rights & R_OWN) == R_OWN {
+		acl.entries().insert(0, accessControlEntry{user: newUser, rights: rights})
+
+		return true
+	}
+
+	// Otherwise, just add the user and rights to the end
+	acl.entries().push(accessControlEntry{user: newUser, rights: rights})
+
+	return true
+}
+
+// Is the entry for an owner of the file?
+func (e accessControlEntry) isOwner() bool {
+	return !e.deny && (e.effective()&R_OWN) == R_OWN
+}
+
+// Keep the owners at the front of the list after the entry at idx has
+// become, or stopped being, an owner. A new owner moves to the very
+// front, where addEntry would have put them, and a former owner moves
+// to just behind the remaining owners.
+func (acl *accessControlList) reorder(idx int) {
+	entry := acl.ace.remove(idx)
+
+	at := 0
+	if !entry.isOwner() {
+		at = acl.ace.ownerCount()
+	}
+
+	acl.ace.insert(at, entry)
+}
+
+// Add an entry to the end of the list just as it is, without moving
+// owners to the front. Used to load lists that are already in order.
+// ok is false if the user already has an entry of the same kind.
+func (acl *accessControlList) appendEntry(newEntry accessControlEntry) (ok bool) {
+	if acl.ace.indexOf(newEntry.user, newEntry.deny) >= 0 {
+		return false
+	}
+
+	acl.entries().push(newEntry)
+
+	return true
+}
+
+// Creates a string for printing of the contents of an ACL
+func (acl *accessControlList) String() (str string) {
+	str = fmt.Sprintf("printList: (")
+
+	// empty check
+	if acl.ace == nil {
+		str += fmt.Sprintf(" empty access control list )\n")
+		return
+	}
+
+	// Add the filename
+	str += fmt.Sprintf("File: %s. ", acl.filename)
+	if acl.noInherit {
+		str += fmt.Sprintf("Inherits nothing. ")
+	}
+
+	// Add the stringified version of the ACE slice
+	// if there are any entries, stringify them
+	if acl.ace.len() > 0 {
+		for _, entry := range acl.ace.all() {
+			if entry.deny {
+				str += fmt.Sprintf(", deny %s (%s%s%s)", entry.user, entry.effective(),
+					entry.roleNames(), entry.timeLimits())
+			} else {
+				str += fmt.Sprintf(", %s (%s%s%s)", entry.user, entry.effective(),
+					entry.roleNames(), entry.timeLimits())
+			}
+		}
+	} else {
+		str += fmt.Sprintf(" No entries.")
+	}
+
+	str += fmt.Sprintf(") \n")
+
+	return
+}
+
+// Generate a string of rights (one character a piece) from a rights
+// variable
+func (r right) String() (str string) {
+	// if there are no rights return an empty string
+	if r == 0 {
+		return ""
+	}
+
+	// If the right bit for each permission is 'on', add the
+	// appropriate letter to the string to return.
+	for _, def := range registry.list() {
+		if (r & def.bit) == def.bit {
+			str += string(def.letter)
+		}
+	}
+
+	return
+}
+
+// Returns if the right is valid: every bit in it is a known right
+func (r right) valid() bool {
+	return r&^registry.all() == 0
+}
+
+// Returns if the right is valid single right (read OR write, not both, etc)
+func (r right) validSingle() bool {
+	return r != 0 && r&(r-1) == 0 && r.valid()
+}
+
+// Delete a right from a user in an ACL
+func (acl *accessControlList) deleteRight(r right, username string) (ok bool) {
+	// checks
+	// If the thing is empty, fail
+	if acl.ace.len() < 1 {
+		return false
+	}
+
+	// Check if the right is valid
+	if !r.validSingle() {
+		return false
+	}
+
+	// find the user and remove the rights
+	if idx := acl.ace.indexOf(username, false); idx >= 0 {
+		// Clear the bit and return success
+		entry := acl.ace.at(idx)
+		changed := entry
+		changed.rights &= ^(r)
+		acl.ace.set(idx, changed)
+
+		// No longer an owner, so no longer at the front
+		if entry.isOwner() && !changed.isOwner() {
+			acl.reorder(idx)
+		}
+		return true
+	}
+
+	// Fallthrough fail
+	return false
+}
+
+// Add a right to a user in an ACL
+func (acl *accessControlList) addRight(r right, username string) (ok bool) {
+	// checks
+	// If the thing is empty, fail
+	if acl.ace.len() < 1 {
+		return false
+	}
+
+	// Check if the right is valid
+	if !r.validSingle() {
+		return false
+	}
+
+	// find the user and add the rights
+	if idx := acl.ace.indexOf(username, false); idx >= 0 {
+		// Set the bit and return success
+		entry := acl.ace.at(idx)
+		changed := entry
+		changed.rights |= (r)
+		acl.ace.set(idx, changed)
+
+		// New owners go to the front, as in addEntry
+		if !entry.isOwner() && changed.isOwner() {
+			acl.reorder(idx)
+		}
+		return true
+	}
+
+	// Fallthrough fail
+	return false
+
+}
+
+// Delete an entry by username from an ACL. Only the user's allow
+// entry goes, any deny entry for them stays until removed with
+// deleteDeny.
+func (acl *accessControlList) deleteEntry(username string) (ok bool) {
+	// checks
+	// If the thing is empty, succeed
+	if acl.ace.len() < 1 {
+		return true
+	}
+
+	// Look the username up
+	if idx := acl.ace.indexOf(username, false); idx >= 0 {
+		// If the username is there, cut it out, so to speak
+		acl.ace.remove(idx)
+		return true
+	}
+
+	// If the user is not found, they aren't in the list, therefore
+	// success!
+	return true
+}
+
+// Add a deny entry for a user, taking away rights. Deny entries
+// always go at the end of the list, after the owners and other
+// entries. ok is false if the user already has a deny entry.
+func (acl *accessControlList) addDenyEntry(newUser string, rights right) (ok bool) {
+	// Check for duplicate usernames
+	if acl.ace.indexOf(newUser, true) >= 0 {
+		return false
+	}
+
+	acl.entries().push(accessControlEntry{user: newUser, rights: rights, deny: true})
+
+	return true
+}
+
+// Deny a right to a user in an ACL. The user's deny entry is created
+// at the end of the list if they don't have one yet.
+func (acl *accessControlList) addDeny(r right, username string) (ok bool) {
+	// Check if the right is valid
+	if !r.validSingle() {
+		return false
+	}
+
+	if idx := acl.ace.indexOf(username, true); idx >= 0 {
+		entry := acl.ace.at(idx)
+		entry.rights |= r
+		acl.ace.set(idx, entry)
+		return true
+	}
+
+	// No deny entry yet, so make one
+	return acl.addDenyEntry(username, r)
+}
+
+// Stop denying a right to a user in an ACL. Once a deny entry has no
+// rights left it denies nothing, so it is removed.
+func (acl *accessControlList) deleteDeny(r right, username string) (ok bool) {
+	// Check if the right is valid
+	if !r.validSingle() {
+		return false
+	}
+
+	if idx := acl.ace.indexOf(username, true); idx >= 0 {
+		entry := acl.ace.at(idx)
+		entry.rights &= ^(r)
+		acl.ace.set(idx, entry)
+
+		if entry.rights == 0 {
+			acl.ace.remove(idx)
+		}
+		return true
+	}
+
+	// Fallthrough fail
+	return false
+}
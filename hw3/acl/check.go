@@ -0,0 +1,135 @@
+package acl
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Every built in right. There may be more, see rights.go
+const R_ALL = R_OWN | R_READ | R_WRITE | R_EXEC
+
+// The answer to "can user U do R on file F?", along with why.
+type decision struct {
+	allowed  bool
+	filename string
+	user     string
+	wanted   right
+	// The entries that apply to the user: their own and those of
+	// any groups they're in. Empty if none do.
+	entries []accessControlEntry
+	// Everything those entries grant together
+	granted right
+	// Deny entries that apply to the user, and what they take away
+	denials []accessControlEntry
+	denied  right
+	// Rights that were asked for but not granted
+	missing right
+	// Set when the question itself didn't make sense
+	reason string
+}
+
+// Decide whether username holds every right in r on the file the
+// list is for. A user's rights are those of their own entry plus those
+// of every group entry they're a member of, each with the rights of its
+// roles, see roles.go. Users without any entry
+// are denied everything, as is anyone asking for rights that don't exist.
+//
+// Deny entries are applied after all allow entries, wherever they sit
+// in the list (deny-before-allow, as NTFS does it): a right denied to
+// the user, or to any group they're in, is never granted.
+//
+// Entries that aren't active yet, or have expired, are ignored. The
+// check is as of now(), see checkAt for any other time.
+func (acl *accessControlList) check(username string, r right) (d decision) {
+	return acl.checkAt(username, r, now())
+}
+
+// Same as check, but as of time t
+func (acl *accessControlList) checkAt(username string, r right, t time.Time) (d decision) {
+	d = decision{filename: acl.filename, user: username, wanted: r, missing: r}
+
+	// Bits that aren't rights can never be granted
+	if !r.valid() {
+		d.reason = fmt.Sprintf("%d is not a valid set of rights", r)
+		return
+	}
+
+	// Only the user's own entries and group entries can apply
+	for _, entry := range acl.ace.forUser(username) {
+		group, isGroup := entry.group()
+		if entry.user != username &&
+			!(isGroup && acl.groups.isMember(group, username)) {
+			continue
+		}
+		if !entry.activeAt(t) {
+			continue
+		}
+
+		if entry.deny {
+			d.denials = append(d.denials, entry)
+			d.denied |= entry.effective()
+		} else {
+			d.entries = append(d.entries, entry)
+			d.granted |= entry.effective()
+		}
+	}
+
+	d.missing = r &^ (d.granted &^ d.denied)
+	d.allowed = len(d.entries) > 0 && d.missing == 0
+
+	return
+}
+
+// Same as check, but for a file in the store, going by every list
+// that applies to it (see glob.go). Files no list applies to deny
+// everyone.
+func (s *aclStore) check(filename, username string, r right) (d decision) {
+	acl, ok := s.resolve(filename)
+	if !ok {
+		return decision{filename: filename, user: username, wanted: r,
+			missing: r, reason: "no access control list for file"}
+	}
+
+	return acl.check(username, r)
+}
+
+// Stringify a decision, giving the reason for it
+func (d decision) String() (str string) {
+	if d.allowed {
+		str = "allow: "
+	} else {
+		str = "deny: "
+	}
+
+	str += fmt.Sprintf("%s wants %s (%d) on %s, ", d.user, d.wanted, d.wanted, d.filename)
+
+	switch {
+	case d.reason != "":
+		str += d.reason
+	case len(d.entries) == 0:
+		str += "no entry for user"
+	default:
+		str += fmt.Sprintf("%s grants %s", entryNames(d.entries), d.granted)
+	}
+
+	if len(d.denials) > 0 {
+		str += fmt.Sprintf(", deny %s takes %s", entryNames(d.denials), d.denied)
+	}
+
+	if !d.allowed && d.reason == "" && len(d.entries) > 0 {
+		str += fmt.Sprintf(", missing %s", d.missing)
+	}
+
+	return
+}
+
+// Names of the entries behind a decision, with any roles they hold,
+// e.g. "vegdahl %reviewer, @ops"
+func entryNames(entries []accessControlEntry) string {
+	names := make([]string, len(entries))
+	for idx, entry := range entries {
+		names[idx] = entry.user + entry.roleNames()
+	}
+	return strings.Join(names, ", ")
+}
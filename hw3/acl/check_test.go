@@ -0,0 +1,58 @@
+package acl
+
+import (
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	acls = newACLStore()
+
+	if err := acls.parseInputFile("../acl1.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	if d := acls.check("main.c", "vegdahl", R_READ|R_WRITE); !d.allowed {
+		t.Errorf("Fail: %v\n", d)
+	}
+
+	if d := acls.check("main.c", "ubuntu", R_READ|R_EXEC); d.allowed || d.missing != R_EXEC {
+		t.Errorf("Fail: %v\n", d)
+	}
+
+	if d := acls.check("main.c", "nobody", R_READ); d.allowed || len(d.entries) != 0 {
+		t.Errorf("Fail: %v\n", d)
+	}
+
+	if d := acls.check("grade.sh", "vegdahl", R_READ); d.allowed {
+		t.Errorf("Fail: %v\n", d)
+	}
+}
+
+func TestDeny(t *testing.T) {
+	acls = newACLStore()
+
+	if err := acls.parseInputFile("../acl8.txt"); err != nil {
+		t.Fatal(err)
+	}
+	acl, _ := acls.lookup("main.c")
+
+	// Denied through their own entry, even though the group allows it
+	if d := acl.check("mallory", R_WRITE); d.allowed || d.denied != R_WRITE {
+		t.Errorf("Fail: %v\n", d)
+	}
+	if d := acl.check("vegdahl", R_WRITE); !d.allowed {
+		t.Errorf("Fail: %v\n", d)
+	}
+
+	// Denying to the group denies to its members
+	acl.addDeny(R_READ, "@ops")
+	if d := acl.check("vegdahl", R_READ); d.allowed {
+		t.Errorf("Fail: %v\n", d)
+	}
+
+	// An emptied deny entry goes away
+	acl.deleteDeny(R_WRITE, "mallory")
+	if acl.ace.len() != 3 {
+		t.Errorf("Fail: %v\n", acl)
+	}
+}
@@ -0,0 +1,428 @@
+package acl
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The kinds of words a command takes after it
+type argKind int
+
+const (
+	A_NAME argKind = iota
+	A_RIGHT
+	A_COUNT
+	A_WHEN
+)
+
+// Every command, and what it expects to follow it
+var commandArgs = map[string][]argKind{
+	"file":      {A_NAME},
+	"ae":        {A_NAME, A_RIGHT},
+	"ar":        {A_NAME, A_RIGHT},
+	"dr":        {A_NAME, A_RIGHT},
+	"ad":        {A_NAME, A_RIGHT},
+	"rd":        {A_NAME, A_RIGHT},
+	"ck":        {A_NAME, A_RIGHT},
+	"de":        {A_NAME},
+	"ga":        {A_NAME, A_NAME},
+	"gr":        {A_NAME, A_NAME},
+	"undo":      {A_COUNT},
+	"redo":      {A_COUNT},
+	"as":        {A_NAME},
+	"at":        {A_NAME, A_RIGHT, A_WHEN},
+	"rules":     {A_NAME},
+	"purge":     {},
+	"assign":    {A_NAME, A_NAME},
+	"revoke":    {A_NAME, A_NAME},
+	"block":     {A_NAME},
+	"inherit":   {A_NAME},
+	"effective": {A_NAME},
+}
+
+// What each command does, with what follows it, for help text
+var commandHelp = map[string]string{
+	"file":      "file <file>: apply the commands that follow to a file's list",
+	"ae":        "ae <user> <rights>: add an entry for a user",
+	"ar":        "ar <user> <right>: add a right to a user's entry",
+	"dr":        "dr <user> <right>: delete a right from a user's entry",
+	"ad":        "ad <user> <right>: deny a right to a user, whatever else grants it",
+	"rd":        "rd <user> <right>: remove a right from a user's deny entry",
+	"ck":        "ck <user> <rights>: check whether a user holds every one of the rights",
+	"de":        "de <user>: delete a user's entry",
+	"ga":        "ga <group> <user>: add a user to a group",
+	"gr":        "gr <group> <user>: remove a user from a group",
+	"undo":      "undo <n>: undo the last n changes made in this file or session",
+	"redo":      "redo <n>: redo the last n changes undone",
+	"as":        "as <user>: run the commands that follow as a user, who has to own a list to change it",
+	"at":        "at <user> <rights> <time>: add rights until a time, or for a while like 30d, to a user without a lasting entry",
+	"rules":     "rules <path>: print which lists apply to a path, and what they add up to",
+	"purge":     "purge: remove every expired entry",
+	"assign":    "assign <user> <role>: give a user a role, and the rights that come with it",
+	"revoke":    "revoke <user> <role>: take a role away from a user",
+	"block":     "block <file>: stop a file's list inheriting from the directories above it",
+	"inherit":   "inherit <file>: let a file's list inherit from the directories above it again",
+	"effective": "effective <path>: print the list that applies to a path, and where each entry came from",
+}
+
+// A single parsed command from a command file
+type command struct {
+	verb token
+	args []token
+	// The value of the A_RIGHT, A_COUNT or A_WHEN word, if the
+	// command has one
+	rights right
+	count  int
+	until  time.Time
+}
+
+// Parse a rights word: a number, letters like 'rw', or names like
+// 'read,write', see parseRights
+func parseRight(t token) (r right, err error) {
+	return parseRights(t.text)
+}
+
+// Turn the words of a command file into commands. Newlines don't
+// matter, each command just takes as many of the next words as
+// commandArgs says it has, whatever they are, so a user can be named
+// 'file' or 'ar' like anyone else.
+//
+// A bad argument is still taken, and parsing carries on after it, so
+// every problem in the file gets reported. A word that isn't a command
+// where one should be is skipped.
+func parseCommands(tokens []token) (cmds []command, diags diagnostics) {
+	for idx := 0; idx < len(tokens); {
+		verb := tokens[idx]
+		idx++
+
+		kinds, ok := commandArgs[verb.text]
+		if !ok {
+			diags.add(verb, D_UNKNOWN_COMMAND, "not a command")
+			continue
+		}
+
+		cmd := command{verb: verb}
+		complete := true
+
+		for _, kind := range kinds {
+			if idx >= len(tokens) {
+				diags.missing(verb.pos, "%s is missing arguments", verb.text)
+				complete = false
+				break
+			}
+
+			arg := tokens[idx]
+			idx++
+
+			switch kind {
+			case A_RIGHT:
+				r, err := parseRight(arg)
+				if err != nil {
+					diags.add(arg, D_BAD_RIGHT, "%v", err)
+					complete = false
+				}
+				cmd.rights = r
+
+			case A_COUNT:
+				n, err := strconv.Atoi(arg.text)
+				if err != nil || n < 1 {
+					diags.add(arg, D_SYNTAX, "not a count of 1 or more")
+					complete = false
+				}
+				cmd.count = n
+
+			case A_WHEN:
+				// Durations are from when the file is parsed, so every
+				// command in it agrees on when that is
+				t, err := parseExpiry(arg.text, now())
+				if err != nil {
+					diags.add(arg, D_SYNTAX, "%v", err)
+					complete = false
+				}
+				cmd.until = t
+			}
+
+			cmd.args = append(cmd.args, arg)
+		}
+
+		if complete {
+			cmds = append(cmds, cmd)
+		}
+	}
+
+	return
+}
+
+// A commandRunner applies commands to a store, remembering which list
+// they apply to and every problem they run into. It also keeps the
+// history of changes made, for undo and redo and the audit log.
+type commandRunner struct {
+	store *aclStore
+	acl   *accessControlList
+	diags diagnostics
+	// Changes that can be undone, and undone changes that can be
+	// redone, most recent last
+	done   []change
+	undone []change
+	// Every change, undo and redo, in the order they happened
+	log []auditEvent
+	// The user commands are being run as, see owners.go
+	principal      string
+	fixedPrincipal bool
+}
+
+// Make a runner for a store. Until a 'file' command says otherwise,
+// commands apply to the first list in the store.
+func newCommandRunner(store *aclStore) *commandRunner {
+	return &commandRunner{store: store, acl: store.first()}
+}
+
+// Run one command, printing what it does
+func (cr *commandRunner) run(cmd command) {
+	verb := cmd.verb.text
+
+	// Switching lists, changing groups and the like don't need a list
+	switch verb {
+	case "file":
+		target := cmd.args[0]
+		found, ok := cr.store.lookup(target.text)
+		if !ok {
+			cr.diags.add(target, D_UNKNOWN_FILE, "no access control list for file")
+			return
+		}
+		cr.acl = found
+
+		fmt.Fprintf(cr.store.messages(), "Working on file %s \n", target.text)
+		return
+
+	case "as":
+		name := cmd.args[0].text
+		if cr.fixedPrincipal && name != cr.principal {
+			cr.diags.add(cmd.args[0], D_DENIED, "commands have to run as %s", cr.principal)
+			return
+		}
+		cr.principal = name
+
+		fmt.Fprintf(cr.store.messages(), "Acting as %s \n", name)
+		return
+
+	case "ga", "gr":
+		// Groups don't belong to anybody, so only an administrator
+		// may change them
+		if cr.principal != "" {
+			cr.diags.add(cmd.verb, D_DENIED, "%s can't change groups", cr.principal)
+			return
+		}
+
+		group, member := cmd.args[0].text, cmd.args[1].text
+		if verb == "ga" && !cr.store.checkKnownName(cmd.args[1], &cr.diags) {
+			return
+		}
+		event := auditEvent{group: group, user: member,
+			beforeIdx: cr.store.groups.indexOf(group, member)}
+
+		if verb == "ga" {
+			fmt.Fprintf(cr.store.messages(), "Add member %s to group %s \n", member, group)
+			cr.store.groups.addMember(group, member)
+		} else {
+			fmt.Fprintf(cr.store.messages(), "Remove member %s from group %s \n", member, group)
+			if !cr.store.groups.removeMember(group, member) {
+				cr.diags.add(cmd.args[1], D_UNKNOWN_USER, "not a member of group %s", group)
+			}
+		}
+
+		event.afterIdx = cr.store.groups.indexOf(group, member)
+		cr.record(cmd, event)
+		return
+
+	case "rules":
+		target := cmd.args[0]
+		if !cr.store.printRules(target.text) {
+			cr.diags.add(target, D_UNKNOWN_FILE, "no access control list applies to file")
+		}
+		return
+
+	case "effective":
+		target := cmd.args[0]
+		if !cr.store.printEffective(target.text) {
+			cr.diags.add(target, D_UNKNOWN_FILE, "no access control list applies to file")
+		}
+		return
+
+	case "block", "inherit":
+		target := cmd.args[0]
+		found, ok := cr.store.lookup(target.text)
+		if !ok {
+			cr.diags.add(target, D_UNKNOWN_FILE, "no access control list for file")
+			return
+		}
+		if !cr.mayChange(target.text) {
+			cr.diags.add(cmd.verb, D_DENIED, "%s doesn't own %s", cr.principal, target.text)
+			return
+		}
+
+		event := auditEvent{filename: target.text, inherit: true, beforeIdx: found.inheritIdx()}
+		if verb == "block" {
+			fmt.Fprintf(cr.store.messages(), "Block inheritance on file %s \n", target.text)
+		} else {
+			fmt.Fprintf(cr.store.messages(), "Inherit again on file %s \n", target.text)
+		}
+		ok = found.setInherit(verb == "inherit")
+
+		event.afterIdx = found.inheritIdx()
+		cr.record(cmd, event)
+
+		switch {
+		case ok:
+		case verb == "block":
+			cr.diags.add(target, D_DUPLICATE, "already inherits nothing")
+		default:
+			cr.diags.add(target, D_DUPLICATE, "already inherits")
+		}
+		return
+
+	case "purge":
+		fmt.Fprintf(cr.store.messages(), "Purge expired entries \n")
+		cr.purge(cmd)
+		return
+
+	case "undo":
+		fmt.Fprintf(cr.store.messages(), "Undo %d changes \n", cmd.count)
+		if !cr.undo(cmd, cmd.count) {
+			cr.diags.add(cmd.args[0], D_HISTORY, "can't undo %d of %d changes", cmd.count, len(cr.done))
+		}
+		return
+
+	case "redo":
+		fmt.Fprintf(cr.store.messages(), "Redo %d changes \n", cmd.count)
+		if !cr.redo(cmd, cmd.count) {
+			cr.diags.add(cmd.args[0], D_HISTORY, "can't redo %d of %d changes", cmd.count, len(cr.undone))
+		}
+		return
+	}
+
+	user := cmd.args[0]
+	d := cmd.rights
+
+	// Nothing to alter if the input file had no lists
+	if cr.acl == nil {
+		cr.diags.add(cmd.verb, D_UNKNOWN_FILE, "no access control list to apply command to")
+		return
+	}
+
+	// Checking doesn't change anything, so anyone may do it. It goes
+	// by every list that applies to the file, as check does anywhere
+	// else.
+	if verb == "ck" {
+		fmt.Fprintf(cr.store.messages(), "Check %v \n", cr.store.check(cr.acl.filename, user.text, d))
+		return
+	}
+
+	// Giving anything to somebody the user directory doesn't know is
+	// most likely a typo
+	switch verb {
+	case "ae", "ar", "ad", "at", "assign":
+		if !cr.store.checkKnownName(user, &cr.diags) {
+			return
+		}
+	}
+
+	// Only owners may change a list
+	if !cr.mayChange(cr.acl.filename) {
+		cr.diags.add(cmd.verb, D_DENIED, "%s doesn't own %s", cr.principal, cr.acl.filename)
+		return
+	}
+
+	// What the entry and list look like before they change
+	owners, lasting := cr.acl.owners()
+	deny := verb == "ad" || verb == "rd"
+	event := auditEvent{filename: cr.acl.filename, user: user.text, deny: deny}
+	event.before, event.beforeIdx = cr.acl.entryAt(user.text, deny)
+
+	// add or delete as needed
+	ok := true
+	switch verb {
+	case "dr":
+		fmt.Fprintf(cr.store.messages(), "Delete right")
+		ok = cr.acl.deleteRight(d, user.text)
+	case "ar":
+		fmt.Fprintf(cr.store.messages(), "Add right")
+		ok = cr.acl.addRight(d, user.text)
+	case "ad":
+		fmt.Fprintf(cr.store.messages(), "Add deny")
+		ok = cr.acl.addDeny(d, user.text)
+	case "rd":
+		fmt.Fprintf(cr.store.messages(), "Remove deny")
+		ok = cr.acl.deleteDeny(d, user.text)
+	case "at":
+		fmt.Fprintf(cr.store.messages(), "Add rights until %s", formatTimeLimit(cmd.until))
+		if !d.valid() {
+			cr.diags.add(cmd.args[1], D_BAD_RIGHT, "%d has bits that aren't rights", d)
+			return
+		}
+		ok = cr.acl.addTemporary(d, user.text, cmd.until)
+	case "ae":
+		fmt.Fprintf(cr.store.messages(), "Add entry")
+		if !d.valid() {
+			cr.diags.add(cmd.args[1], D_BAD_RIGHT, "%d has bits that aren't rights", d)
+			return
+		}
+		ok = cr.acl.addEntry(user.text, d)
+
+	case "de":
+		fmt.Fprintf(cr.store.messages(), "Delete user %s \n", user.text)
+		cr.acl.deleteEntry(user.text)
+
+	case "assign", "revoke":
+		role := cmd.args[1]
+		name := strings.TrimPrefix(role.text, rolePrefix)
+		if !roles.defined(name) {
+			cr.diags.add(role, D_UNKNOWN_ROLE, "no such role")
+			return
+		}
+
+		if verb == "assign" {
+			fmt.Fprintf(cr.store.messages(), "Add role %s on user %s \n", name, user.text)
+			ok = cr.acl.addRole(name, user.text)
+		} else {
+			fmt.Fprintf(cr.store.messages(), "Remove role %s from user %s \n", name, user.text)
+			ok = cr.acl.deleteRole(name, user.text)
+		}
+	}
+
+	// And what it looks like after
+	event.after, event.afterIdx = cr.acl.entryAt(user.text, deny)
+
+	// And add some pretty info text
+	if verb != "de" && verb != "assign" && verb != "revoke" {
+		fmt.Fprintf(cr.store.messages(), " = %d on user %s \n", d, user.text)
+	}
+
+	// Changes that break the ownership rules are taken back
+	if problem := cr.store.ownerProblem(cr.acl, owners, lasting); problem != "" {
+		event.reverse().apply(cr.store)
+		cr.diags.add(user, D_INVARIANT, "%s", problem)
+		return
+	}
+	cr.record(cmd, event)
+
+	// Work out why it failed
+	switch {
+	case ok:
+	case verb == "ae":
+		cr.diags.add(user, D_DUPLICATE, "already has an entry on file %s", cr.acl.filename)
+	case verb == "assign":
+		cr.diags.add(cmd.args[1], D_DUPLICATE, "%s already has the role on file %s", user.text, cr.acl.filename)
+	case verb == "revoke":
+		cr.diags.add(cmd.args[1], D_UNKNOWN_ROLE, "%s doesn't have the role on file %s", user.text, cr.acl.filename)
+	case verb == "at":
+		cr.diags.add(user, D_DUPLICATE, "already has an entry on file %s, which would expire with the new rights",
+			cr.acl.filename)
+	case !d.validSingle():
+		cr.diags.add(cmd.args[1], D_BAD_RIGHT, "%d is not a single right", d)
+	default:
+		cr.diags.add(user, D_UNKNOWN_USER, "no matching entry on file %s", cr.acl.filename)
+	}
+}
@@ -0,0 +1,93 @@
+package acl
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The kinds of problems parsing or running a file can turn up
+type diagKind int
+
+const (
+	D_SYNTAX diagKind = iota
+	D_UNKNOWN_COMMAND
+	D_BAD_RIGHT
+	D_UNKNOWN_FILE
+	D_UNKNOWN_USER
+	D_HISTORY
+	D_DUPLICATE
+	D_DENIED
+	D_INVARIANT
+	D_UNKNOWN_ROLE
+)
+
+// Names for each kind of diagnostic, for printing
+var diagKindNames = map[diagKind]string{
+	D_SYNTAX:          "syntax error",
+	D_UNKNOWN_COMMAND: "unknown command",
+	D_BAD_RIGHT:       "bad right",
+	D_UNKNOWN_FILE:    "unknown file",
+	D_UNKNOWN_USER:    "unknown user",
+	D_HISTORY:         "history",
+	D_DUPLICATE:       "duplicate entry",
+	D_DENIED:          "permission denied",
+	D_INVARIANT:       "ownership rule",
+	D_UNKNOWN_ROLE:    "unknown role",
+}
+
+func (k diagKind) String() string {
+	return diagKindNames[k]
+}
+
+// A diagnostic is one problem found in a file, with where it is and
+// the token that caused it (empty when the problem is a missing token).
+type diagnostic struct {
+	pos   position
+	kind  diagKind
+	token string
+	msg   string
+}
+
+// e.g. commands3.txt:5:1: bad right: 150 is not a single right ("150").
+// Problems that aren't from a file have no position to print.
+func (d diagnostic) Error() (str string) {
+	str = fmt.Sprintf("%v: %v: %s", d.pos, d.kind, d.msg)
+	if d.pos.filename == "" {
+		str = fmt.Sprintf("%v: %s", d.kind, d.msg)
+	}
+	if d.token != "" {
+		str += fmt.Sprintf(" (%q)", d.token)
+	}
+	return
+}
+
+// All the problems found in a file. Parsers keep going after most
+// problems, so one run can report every one of them.
+type diagnostics []diagnostic
+
+// Record a problem with a token
+func (ds *diagnostics) add(t token, kind diagKind, format string, a ...interface{}) {
+	*ds = append(*ds, diagnostic{t.pos, kind, t.text, fmt.Sprintf(format, a...)})
+}
+
+// Record a problem where a token should have been, but wasn't
+func (ds *diagnostics) missing(pos position, format string, a ...interface{}) {
+	*ds = append(*ds, diagnostic{pos, D_SYNTAX, "", fmt.Sprintf(format, a...)})
+}
+
+// One diagnostic per line
+func (ds diagnostics) Error() string {
+	strs := make([]string, len(ds))
+	for idx, d := range ds {
+		strs[idx] = d.Error()
+	}
+	return strings.Join(strs, "\n")
+}
+
+// nil if there were no problems, otherwise the problems as an error
+func (ds diagnostics) err() error {
+	if len(ds) == 0 {
+		return nil
+	}
+	return ds
+}
@@ -0,0 +1,46 @@
+package acl
+
+import (
+	"testing"
+)
+
+func TestDiagnostics(t *testing.T) {
+	acls = newACLStore()
+
+	if err := acls.parseInputFile("../acl1.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 150 has bits that aren't rights, so the file doesn't even run
+	err := acls.parseCommandFile("../commands3.txt", "", "")
+	diags, ok := err.(diagnostics)
+	if !ok || len(diags) != 1 {
+		t.Fatalf("Fail: %v\n", err)
+	}
+
+	// ar nuxoll 150
+	if d := diags[0]; d.kind != D_BAD_RIGHT || d.pos.line != 6 || d.token != "150" {
+		t.Errorf("Fail: %v\n", d)
+	}
+
+	// Failed, so none of the good commands took effect either
+	if d := acls.check("main.c", "vegdahl", R_OWN); d.allowed {
+		t.Errorf("Fail: %v\n", d)
+	}
+
+	// Every problem with a line gets reported, with its column
+	lines := lex("test.txt", "ar root\nzz 4\nar  root 2 de")
+	_, diags = parseCommands(flatten(lines))
+	if len(diags) != 3 || diags[0].kind != D_BAD_RIGHT ||
+		diags[1].kind != D_UNKNOWN_COMMAND ||
+		diags[2].kind != D_SYNTAX || diags[2].pos.col != 12 {
+		t.Errorf("Fail: %v\n", diags)
+	}
+
+	// Users may be named like commands, arguments go by count
+	cmds, diags := parseCommands(flatten(lex("test.txt", "ar file 4\nae ar 6 de undo")))
+	if len(diags) != 0 || len(cmds) != 3 || cmds[0].args[0].text != "file" ||
+		cmds[1].args[0].text != "ar" || cmds[2].args[0].text != "undo" {
+		t.Errorf("Fail: %v %v\n", cmds, diags)
+	}
+}
@@ -0,0 +1,426 @@
+package acl
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// A change to one entry of a list between two stores. An entry that
+// was added has no rights (or roles, or time limits) before, and one
+// that was removed has none after.
+type entryChange struct {
+	filename    string
+	user        string
+	deny        bool
+	added       bool
+	removed     bool
+	before      right
+	after       right
+	beforeRoles []string
+	afterRoles  []string
+	// Time limits, see expiry.go
+	beforeFrom, beforeUntil time.Time
+	afterFrom, afterUntil   time.Time
+}
+
+// The change from entry before to entry after, for the same user. An
+// entry that was added has no before, and one that was removed has no
+// after.
+func changeOf(filename string, before, after accessControlEntry, added, removed bool) (c entryChange) {
+	c = entryChange{filename: filename, added: added, removed: removed}
+	if !added {
+		c.user, c.deny = before.user, before.deny
+		c.before, c.beforeRoles = before.rights, before.roles
+		c.beforeFrom, c.beforeUntil = before.notBefore, before.expires
+	}
+	if !removed {
+		c.user, c.deny = after.user, after.deny
+		c.after, c.afterRoles = after.rights, after.roles
+		c.afterFrom, c.afterUntil = after.notBefore, after.expires
+	}
+	return
+}
+
+// Do the entry's time limits differ?
+func (c entryChange) limitsChanged() bool {
+	return !c.beforeFrom.Equal(c.afterFrom) || !c.beforeUntil.Equal(c.afterUntil)
+}
+
+// Stringify a change, e.g. "main.c: vegdahl rw -> r"
+func (c entryChange) String() (str string) {
+	user := c.user
+	if c.deny {
+		user = "deny " + user
+	}
+
+	before := fmt.Sprintf("%s%s%s", c.before, accessControlEntry{roles: c.beforeRoles}.roleNames(),
+		accessControlEntry{notBefore: c.beforeFrom, expires: c.beforeUntil}.timeLimits())
+	after := fmt.Sprintf("%s%s%s", c.after, accessControlEntry{roles: c.afterRoles}.roleNames(),
+		accessControlEntry{notBefore: c.afterFrom, expires: c.afterUntil}.timeLimits())
+
+	switch {
+	case c.added:
+		return fmt.Sprintf("%s: + %s (%s)", c.filename, user, after)
+	case c.removed:
+		return fmt.Sprintf("%s: - %s (%s)", c.filename, user, before)
+	}
+
+	str = fmt.Sprintf("%s: ~ %s (%s) -> (%s)", c.filename, user, before, after)
+	if gained := c.gained(); gained != 0 {
+		str += fmt.Sprintf(", gained %s", gained)
+	}
+	if lost := c.lost(); lost != 0 {
+		str += fmt.Sprintf(", lost %s", lost)
+	}
+	for _, role := range missingRoles(c.afterRoles, c.beforeRoles) {
+		str += fmt.Sprintf(", gained role %s", role)
+	}
+	for _, role := range missingRoles(c.beforeRoles, c.afterRoles) {
+		str += fmt.Sprintf(", lost role %s", role)
+	}
+	if c.limitsChanged() {
+		str += ", time limits changed"
+	}
+
+	return
+}
+
+// Rights the entry has after the change but not before
+func (c entryChange) gained() right {
+	return c.after &^ c.before
+}
+
+// Rights the entry had before the change but not after
+func (c entryChange) lost() right {
+	return c.before &^ c.after
+}
+
+// Split a set of rights into single rights, owner first like String()
+func (r right) singles() (rs []right) {
+	for _, def := range registry.list() {
+		if r&def.bit != 0 {
+			rs = append(rs, def.bit)
+		}
+	}
+	return
+}
+
+// Can 'at' make the change's time limits? It can give an entry an
+// expiry when it's added, or move one an entry already has.
+func (c entryChange) byAt() bool {
+	return c.limitsChanged() && !c.deny && c.afterFrom.IsZero() && !c.afterUntil.IsZero() &&
+		(c.added || (c.beforeFrom.IsZero() && !c.beforeUntil.IsZero()))
+}
+
+// Why the change's time limits can't be made by commands, or "" if
+// they can (or didn't change)
+func (c entryChange) unsupported() string {
+	switch {
+	case !c.limitsChanged(), c.removed, c.byAt():
+		return ""
+	case c.added && c.afterFrom.IsZero() && c.afterUntil.IsZero():
+		return ""
+	}
+	return fmt.Sprintf("%s: commands can't change %s's time limits (%s -> %s)", c.filename, c.user,
+		limitsText(c.beforeFrom, c.beforeUntil), limitsText(c.afterFrom, c.afterUntil))
+}
+
+// Time limits for printing on their own, "none" if there aren't any
+func limitsText(from, until time.Time) string {
+	if str := (accessControlEntry{notBefore: from, expires: until}).timeLimits(); str != "" {
+		return strings.TrimSpace(str)
+	}
+	return "none"
+}
+
+// The commands that make the part of the change that adds entries or
+// rights, one per line
+func (c entryChange) grants() (str string) {
+	switch {
+	case c.byAt():
+		// Rights the entry already has are added again, which does
+		// nothing, so it gets the new expiry along with what it gained
+		str = fmt.Sprintf("at %s %d %s\n", c.user, c.after, formatTimeLimit(c.afterUntil))
+	case c.added && !c.deny:
+		str = fmt.Sprintf("ae %s %d\n", c.user, c.after)
+	default:
+		// Deny entries come and go with their rights
+		verb := "ar"
+		if c.deny {
+			verb = "ad"
+		}
+		for _, r := range c.gained().singles() {
+			str += fmt.Sprintf("%s %s %d\n", verb, c.user, r)
+		}
+	}
+
+	// Roles can only be given to allow entries
+	if !c.deny {
+		for _, role := range missingRoles(c.afterRoles, c.beforeRoles) {
+			str += fmt.Sprintf("assign %s %s\n", c.user, role)
+		}
+	}
+
+	return
+}
+
+// The commands that make the part of the change that takes entries or
+// rights away, one per line
+func (c entryChange) revokes() (str string) {
+	if c.removed && !c.deny {
+		return fmt.Sprintf("de %s\n", c.user)
+	}
+
+	if !c.deny {
+		for _, role := range missingRoles(c.beforeRoles, c.afterRoles) {
+			str += fmt.Sprintf("revoke %s %s\n", c.user, role)
+		}
+	}
+
+	verb := "dr"
+	if c.deny {
+		verb = "rd"
+	}
+	for _, r := range c.lost().singles() {
+		str += fmt.Sprintf("%s %s %d\n", verb, c.user, r)
+	}
+
+	return
+}
+
+// A change to the membership of a group
+type memberChange struct {
+	group   string
+	user    string
+	removed bool
+}
+
+// The command that makes the change
+func (c memberChange) commands() string {
+	if c.removed {
+		return fmt.Sprintf("gr %s %s\n", c.group, c.user)
+	}
+	return fmt.Sprintf("ga %s %s\n", c.group, c.user)
+}
+
+func (c memberChange) String() string {
+	if c.removed {
+		return fmt.Sprintf("group %s: - %s", c.group, c.user)
+	}
+	return fmt.Sprintf("group %s: + %s", c.group, c.user)
+}
+
+// A change to whether a list inherits from the directories above it
+type inheritChange struct {
+	filename string
+	blocked  bool
+}
+
+// The command that makes the change
+func (c inheritChange) commands() string {
+	if c.blocked {
+		return fmt.Sprintf("block %s\n", c.filename)
+	}
+	return fmt.Sprintf("inherit %s\n", c.filename)
+}
+
+func (c inheritChange) String() string {
+	if c.blocked {
+		return fmt.Sprintf("%s: inherits nothing", c.filename)
+	}
+	return fmt.Sprintf("%s: inherits", c.filename)
+}
+
+// Everything that differs between two stores
+type storeChanges struct {
+	entries  []entryChange
+	members  []memberChange
+	inherits []inheritChange
+	// Files that only one of the stores has a list for
+	addedFiles   []string
+	removedFiles []string
+	// Groups that only one of the stores has, members or not
+	addedGroups   []string
+	removedGroups []string
+}
+
+// Find the entry for a user in a list, deny or not
+func (acl *accessControlList) findEntry(username string, deny bool) (entry accessControlEntry, ok bool) {
+	if idx := acl.indexOf(username, deny); idx >= 0 {
+		return acl.ace.at(idx), true
+	}
+	return entry, false
+}
+
+// The changes that turn list a into list b. Entries are reported in
+// the order they appear in a, then those only in b.
+func diffLists(a, b *accessControlList) (changes []entryChange) {
+	for _, entry := range a.ace.all() {
+		other, ok := b.findEntry(entry.user, entry.deny)
+		switch {
+		case !ok:
+			changes = append(changes, changeOf(a.filename, entry, other, false, true))
+		case !other.same(entry):
+			changes = append(changes, changeOf(a.filename, entry, other, false, false))
+		}
+	}
+
+	for _, entry := range b.ace.all() {
+		if _, ok := a.findEntry(entry.user, entry.deny); !ok {
+			changes = append(changes, changeOf(b.filename, entry, entry, true, false))
+		}
+	}
+
+	return
+}
+
+// The changes that turn store a into store b
+func diffStores(a, b *aclStore) (changes storeChanges) {
+	for _, filename := range a.order {
+		other, ok := b.lookup(filename)
+		if !ok {
+			changes.removedFiles = append(changes.removedFiles, filename)
+			other = &accessControlList{filename: filename}
+		} else if other.noInherit != a.lists[filename].noInherit {
+			changes.inherits = append(changes.inherits, inheritChange{filename, other.noInherit})
+		}
+		changes.entries = append(changes.entries, diffLists(a.lists[filename], other)...)
+	}
+
+	for _, filename := range b.order {
+		if _, ok := a.lookup(filename); !ok {
+			changes.addedFiles = append(changes.addedFiles, filename)
+			empty := &accessControlList{filename: filename}
+			changes.entries = append(changes.entries, diffLists(empty, b.lists[filename])...)
+		}
+	}
+
+	for _, group := range a.groups.order {
+		if _, ok := b.groups.members[group]; !ok {
+			changes.removedGroups = append(changes.removedGroups, group)
+		}
+		for _, member := range a.groups.members[group] {
+			if !b.groups.isMember(group, member) {
+				changes.members = append(changes.members, memberChange{group, member, true})
+			}
+		}
+	}
+	for _, group := range b.groups.order {
+		if _, ok := a.groups.members[group]; !ok {
+			changes.addedGroups = append(changes.addedGroups, group)
+		}
+		for _, member := range b.groups.members[group] {
+			if !a.groups.isMember(group, member) {
+				changes.members = append(changes.members, memberChange{group, member, false})
+			}
+		}
+	}
+
+	return
+}
+
+// Are there any changes at all?
+func (c storeChanges) empty() bool {
+	return len(c.entries) == 0 && len(c.members) == 0 && len(c.inherits) == 0 &&
+		len(c.addedFiles) == 0 && len(c.removedFiles) == 0 &&
+		len(c.addedGroups) == 0 && len(c.removedGroups) == 0
+}
+
+// Print the changes, one per line
+func printChanges(c storeChanges) {
+	if c.empty() {
+		fmt.Printf("No changes.\n")
+		return
+	}
+
+	for _, filename := range c.removedFiles {
+		fmt.Printf("- file %s\n", filename)
+	}
+	for _, filename := range c.addedFiles {
+		fmt.Printf("+ file %s\n", filename)
+	}
+	for _, group := range c.removedGroups {
+		fmt.Printf("- group %s\n", group)
+	}
+	for _, group := range c.addedGroups {
+		fmt.Printf("+ group %s\n", group)
+	}
+	for _, change := range c.inherits {
+		fmt.Printf("%v\n", change)
+	}
+	for _, change := range c.entries {
+		fmt.Printf("%v\n", change)
+	}
+	for _, change := range c.members {
+		fmt.Printf("%v\n", change)
+	}
+}
+
+// A command file that turns the first store of the diff into the
+// second. Command files can't add or remove whole lists, so changes
+// in files only one of the stores has are left out, and they can only
+// set the time limits 'at' can. Groups come and go with their members,
+// so one can't be taken away, or added without any. skipped says what
+// was left out, a line each.
+//
+// For each list everything granted comes before anything taken away,
+// so a list never runs out of owners part way through.
+func (c storeChanges) commands() (str string, skipped []string) {
+	skip := make(map[string]bool)
+	onlyInOne := append(append([]string(nil), c.addedFiles...), c.removedFiles...)
+	for _, filename := range onlyInOne {
+		skip[filename] = true
+		skipped = append(skipped, fmt.Sprintf("%s is only in one of the files, "+
+			"its changes can't be made by commands", filename))
+	}
+
+	// A group with members gets added along with them
+	adding := make(map[string]bool)
+	for _, change := range c.members {
+		adding[change.group] = adding[change.group] || !change.removed
+	}
+	for _, group := range c.removedGroups {
+		skipped = append(skipped, fmt.Sprintf("group %s is only in the first file, "+
+			"commands can take away its members but not the group", group))
+	}
+	for _, group := range c.addedGroups {
+		if !adding[group] {
+			skipped = append(skipped, fmt.Sprintf("group %s is only in the second file, "+
+				"and has no members for commands to add it with", group))
+		}
+	}
+
+	// Changes are already grouped by file
+	for start := 0; start < len(c.entries); {
+		filename := c.entries[start].filename
+		end := start
+		for end < len(c.entries) && c.entries[end].filename == filename {
+			end++
+		}
+
+		if !skip[filename] {
+			str += fmt.Sprintf("file %s\n", filename)
+			for _, change := range c.entries[start:end] {
+				str += change.grants()
+				if problem := change.unsupported(); problem != "" {
+					skipped = append(skipped, problem)
+				}
+			}
+			for _, change := range c.entries[start:end] {
+				str += change.revokes()
+			}
+		}
+
+		start = end
+	}
+
+	for _, change := range c.inherits {
+		str += change.commands()
+	}
+	for _, change := range c.members {
+		str += change.commands()
+	}
+
+	return
+}
@@ -0,0 +1,97 @@
+package acl
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDiffCommands(t *testing.T) {
+	stores := make([]*aclStore, 0, 2)
+	for _, filename := range []string{"../acl1.txt", "../acl3.txt"} {
+		acls = newACLStore()
+		if err := acls.parseInputFile(filename); err != nil {
+			t.Fatal(err)
+		}
+		stores = append(stores, acls)
+	}
+
+	changes := diffStores(stores[0], stores[1])
+	if len(changes.entries) != 4 || changes.entries[1].lost() != R_READ|R_WRITE {
+		t.Errorf("Fail: %v\n", changes.entries)
+	}
+
+	// Running the commands on the first gives the second
+	str, skipped := changes.commands()
+	cmds, diags := parseCommands(flatten(lex("diff.txt", str)))
+	if len(diags) > 0 || len(skipped) > 0 {
+		t.Fatal(diags, skipped)
+	}
+
+	runner := newCommandRunner(stores[0])
+	for _, cmd := range cmds {
+		runner.run(cmd)
+	}
+	if len(runner.diags) > 0 || !diffStores(stores[0], stores[1]).empty() {
+		t.Errorf("Fail: %v\n%v\n", runner.diags, stores[0])
+	}
+
+	// Time limits are changes too. 'at' can give a new entry an expiry,
+	// or move one, but can't make a lasting entry expire or set when
+	// one starts.
+	dir := t.TempDir()
+	for idx, str := range []string{
+		": main.c\n* crenshaw 15\n* alice 4 until 2030-01-01\n* bob 4\n* carol 4 from 2015-01-01\n",
+		": main.c\n* crenshaw 15\n* alice 4 until 2031-01-01\n* bob 4 until 2030-01-01\n* carol 4\n* dave 6 until 2030-06-01\n",
+	} {
+		filename := filepath.Join(dir, fmt.Sprintf("acl%d.txt", idx))
+		ioutil.WriteFile(filename, []byte(str), 0644)
+		stores[idx] = newACLStore()
+		if err := stores[idx].parseInputFile(filename); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	changes = diffStores(stores[0], stores[1])
+	if len(changes.entries) != 4 || changes.entries[0].String() !=
+		"main.c: ~ alice (r until 2030-01-01T00:00:00Z) -> (r until 2031-01-01T00:00:00Z), time limits changed" {
+		t.Errorf("Fail: %v\n", changes.entries)
+	}
+
+	str, skipped = changes.commands()
+	cmds, _ = parseCommands(flatten(lex("diff.txt", str)))
+	runner = newCommandRunner(stores[0])
+	for _, cmd := range cmds {
+		runner.run(cmd)
+	}
+	left := diffStores(stores[0], stores[1]).entries
+	if len(skipped) != 2 || len(runner.diags) > 0 || len(left) != 2 || left[0].user != "bob" || left[1].user != "carol" {
+		t.Errorf("Fail: %v\n%v\n%v\n%v\n", str, skipped, runner.diags, left)
+	}
+
+	// Groups only one store has are in the diff, even with no members;
+	// one with members is added by adding them
+	a, b := newACLStore(), newACLStore()
+	a.groups.addGroup("old")
+	b.groups.addGroup("empty")
+	b.groups.addMember("ops", "alice")
+	changes = diffStores(a, b)
+	if len(changes.removedGroups) != 1 || len(changes.addedGroups) != 2 {
+		t.Errorf("Fail: %v\n", changes)
+	}
+	str, skipped = changes.commands()
+	if str != "ga ops alice\n" || len(skipped) != 2 || !strings.Contains(skipped[0], "group old") ||
+		!strings.Contains(skipped[1], "group empty") {
+		t.Errorf("Fail: %s%v\n", str, skipped)
+	}
+
+	// Listing the files only one store has leaves the changes alone
+	changes = storeChanges{addedFiles: make([]string, 1, 2), removedFiles: []string{"b.c"}}
+	changes.addedFiles[0] = "a.c"
+	changes.commands()
+	if spare := changes.addedFiles[:2]; spare[1] != "" {
+		t.Errorf("Fail: %v\n", spare)
+	}
+}
@@ -0,0 +1,194 @@
+package acl
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// A store can be given a user directory, the users and groups a system
+// actually has, read from files in the format of /etc/passwd and
+// /etc/group. Entries and commands that name a user or group the
+// directory doesn't have are then rejected, or just warned about (see
+// SetRejectUnknown), along with the closest name it does have, since
+// that's usually a typo.
+//
+// What's checked is every entry of an ACL, JSON or YAML file, the
+// members of groups those and group files define, and the users ae,
+// ar, ad, at, assign and ga give rights or membership to.
+// Taking things away from unknown users is always fine. Group entries
+// count if the group is in the directory or defined in the store (see
+// groups.go), and 'other' (see posix.go) is always fine. Lists read
+// from getfacl text or a directory tree are left alone, the system
+// they came from already knew who its users were.
+
+// The users and groups a system has. Either may be missing, in which
+// case names of that kind aren't checked.
+type userDirectory struct {
+	users      map[string]bool
+	groups     map[string]bool
+	userOrder  []string
+	groupOrder []string
+}
+
+// Read the names out of a file in /etc/passwd or /etc/group format:
+// one entry a line, the name first, then fields separated by colons.
+// fields is how many each line has to have. Blank lines, comments and
+// NIS lines starting with '+' or '-' are skipped.
+func readDirectoryFile(filename, kind string, fields int) (names []string, err error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(messages(), "%s was successfully opened.\nParsing %s from file.\n", filename, kind)
+
+	var diags diagnostics
+	for idx, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") ||
+			strings.HasPrefix(line, "+") || strings.HasPrefix(line, "-") {
+			continue
+		}
+
+		parts := strings.Split(line, ":")
+		if len(parts) < fields || parts[0] == "" {
+			diags.add(token{position{filename, idx + 1, 1}, line}, D_SYNTAX,
+				"expected a name and %d fields after it, separated by ':'", fields-1)
+			continue
+		}
+		names = append(names, parts[0])
+	}
+
+	return names, diags.err()
+}
+
+// Read users from a file in /etc/passwd format and groups from one in
+// /etc/group format. Either filename may be "" to leave that kind
+// unchecked.
+func readUserDirectory(passwdFile, groupFile string) (ud *userDirectory, err error) {
+	ud = new(userDirectory)
+
+	if passwdFile != "" {
+		if ud.userOrder, err = readDirectoryFile(passwdFile, "users", 7); err != nil {
+			return nil, err
+		}
+		ud.users = make(map[string]bool)
+		for _, name := range ud.userOrder {
+			ud.users[name] = true
+		}
+	}
+
+	if groupFile != "" {
+		if ud.groupOrder, err = readDirectoryFile(groupFile, "groups", 4); err != nil {
+			return nil, err
+		}
+		ud.groups = make(map[string]bool)
+		for _, name := range ud.groupOrder {
+			ud.groups[name] = true
+		}
+	}
+
+	return ud, nil
+}
+
+// Why a name an entry or command uses isn't known, with a suggestion
+// if there's a name close to it. "" if it's known, or there's no
+// directory to check it against.
+func (s *aclStore) unknownName(name string) string {
+	ud := s.directory
+	if ud == nil || name == posixOther {
+		return ""
+	}
+
+	group, isGroup := (accessControlEntry{user: name}).group()
+	switch {
+	case isGroup && ud.groups == nil, isGroup && ud.groups[group]:
+		return ""
+	case isGroup:
+		if _, defined := s.groups.members[group]; defined {
+			return ""
+		}
+		problem := fmt.Sprintf("group %s isn't in the user directory", group)
+		if near := closest(group, append(append([]string(nil), ud.groupOrder...), s.groups.order...)); near != "" {
+			problem += fmt.Sprintf(", did you mean %s%s?", groupPrefix, near)
+		}
+		return problem
+
+	case ud.users == nil, ud.users[name]:
+		return ""
+	}
+
+	problem := fmt.Sprintf("user %s isn't in the user directory", name)
+	if near := closest(name, ud.userOrder); near != "" {
+		problem += fmt.Sprintf(", did you mean %s?", near)
+	}
+	return problem
+}
+
+// Reject a token naming an unknown user or group, or warn about it,
+// as the store is set to. ok is false if it was rejected.
+func (s *aclStore) checkKnownName(t token, diags *diagnostics) (ok bool) {
+	problem := s.unknownName(t.text)
+	switch {
+	case problem == "":
+	case s.rejectUnknown:
+		diags.add(t, D_UNKNOWN_USER, "%s", problem)
+		return false
+	default:
+		fmt.Fprintf(s.messages(), "Warning: %v\n", diagnostic{t.pos, D_UNKNOWN_USER, t.text, problem})
+	}
+	return true
+}
+
+// The candidate closest to name, if any is close enough to be a typo
+// of it: about one edit in four letters. Ties go to the first.
+func closest(name string, candidates []string) (best string) {
+	most := 1 + len([]rune(name))/4
+	bestDist := most + 1
+
+	for _, candidate := range candidates {
+		if d := editDistance(name, candidate); d < bestDist {
+			best, bestDist = candidate, d
+		}
+	}
+	return
+}
+
+// How many letters have to be added, removed, changed or swapped with
+// the next to turn a into b
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+
+	// dist[i][j] is the distance between ra[:i] and rb[:j]
+	dist := make([][]int, len(ra)+1)
+	for i := range dist {
+		dist[i] = make([]int, len(rb)+1)
+		dist[i][0] = i
+	}
+	for j := range dist[0] {
+		dist[0][j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+
+			d := dist[i-1][j-1] + cost
+			if dist[i-1][j]+1 < d {
+				d = dist[i-1][j] + 1
+			}
+			if dist[i][j-1]+1 < d {
+				d = dist[i][j-1] + 1
+			}
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] && dist[i-2][j-2]+1 < d {
+				d = dist[i-2][j-2] + 1
+			}
+			dist[i][j] = d
+		}
+	}
+
+	return dist[len(ra)][len(rb)]
+}
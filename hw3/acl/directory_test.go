@@ -0,0 +1,82 @@
+package acl
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUserDirectory(t *testing.T) {
+	SetMessages(ioutil.Discard)
+	defer SetMessages(os.Stdout)
+
+	for _, c := range []struct {
+		a, b string
+		d    int
+	}{
+		{"vegdahl", "vegdahl", 0},
+		{"vegdhal", "vegdahl", 1},
+		{"crenshw", "crenshaw", 1},
+		{"", "root", 4},
+		{"ubuntu", "root", 5},
+	} {
+		if d := editDistance(c.a, c.b); d != c.d {
+			t.Errorf("Fail: %s to %s is %d\n", c.a, c.b, d)
+		}
+	}
+	if near := closest("bob", []string{"root", "crenshaw"}); near != "" {
+		t.Errorf("Fail: %s\n", near)
+	}
+
+	store := NewStore()
+	if err := store.ReadUserDirectory("../passwd1.txt", "../group1.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Everyone in acl1.txt is known, mallory in acl12.txt isn't
+	if err := store.ReadFile("../acl1.txt", F_ACL); err != nil {
+		t.Fatal(err)
+	}
+	err := store.ReadFile("../acl12.txt", F_ACL)
+	if ds, ok := err.(diagnostics); !ok || len(ds) != 1 || ds[0].kind != D_UNKNOWN_USER ||
+		ds[0].pos.line != 6 {
+		t.Errorf("Fail: %v\n", err)
+	}
+
+	// Typos in commands are caught with the name that was meant;
+	// taking rights away from a typo just finds no entry
+	err = store.RunCommandFile("../commands14.txt", "", "")
+	want := "../commands14.txt:1:4: unknown user: user vegdhal isn't in the user directory, did you mean vegdahl? (\"vegdhal\")\n" +
+		"../commands14.txt:2:10: unknown user: user crenshw isn't in the user directory, did you mean crenshaw? (\"crenshw\")\n" +
+		"../commands14.txt:3:4: unknown user: group staf isn't in the user directory, did you mean @staff? (\"@staf\")\n" +
+		"../commands14.txt:5:4: unknown user: no matching entry on file main.c (\"ubunt\")"
+	if err == nil || err.Error() != want {
+		t.Errorf("Fail: %v\n", err)
+	}
+
+	// Group members are users too
+	grouped := NewStore()
+	grouped.ReadUserDirectory("../passwd1.txt", "")
+	err = grouped.ReadGroupFile("../groups1.txt")
+	if ds, ok := err.(diagnostics); !ok || len(ds) != 2 || ds[0].token != "bob" || ds[1].token != "carol" {
+		t.Errorf("Fail: %v\n", err)
+	}
+
+	// Only warned about, they go through
+	store.SetRejectUnknown(false)
+	if err := store.ReadFile("../acl12.txt", F_ACL); err != nil {
+		t.Errorf("Fail: %v\n", err)
+	}
+
+	// Other formats are checked too
+	str, _ := store.Format(F_JSON)
+	filename := filepath.Join(t.TempDir(), "acl.json")
+	ioutil.WriteFile(filename, []byte(str), 0644)
+	store = NewStore()
+	store.ReadUserDirectory("../passwd1.txt", "")
+	if err := store.ReadFile(filename, F_JSON); err == nil || !strings.HasPrefix(err.Error(), "src/: unknown user: user mallory") {
+		t.Errorf("Fail: %v\n", err)
+	}
+}
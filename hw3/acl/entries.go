@@ -0,0 +1,320 @@
+package acl
+
+import (
+	"sort"
+)
+
+// A list's entries are kept in order in blocks of about the square
+// root of their number, rather than in one slice, and indexed by user,
+// so a list of tens of thousands of entries doesn't have to be walked
+// (or copied) from one end to the other to find, add or remove one:
+//
+//   - finding a user's entry looks up the block it's in, then where
+//     that block starts, which is O(√n)
+//   - adding or removing one only shifts the rest of its block, and
+//     adding an owner at the front is no different, also O(√n)
+//   - the owners, and those of them that don't expire, are kept track
+//     of as entries come and go, so they don't have to be counted
+//
+// Blocks split in two when they grow to twice the size they should
+// be, and everything is put back into even blocks if there come to
+// be too many small ones.
+
+// Entries are told apart by their user, and whether they deny
+type entryKey struct {
+	user string
+	deny bool
+}
+
+func (e accessControlEntry) key() entryKey {
+	return entryKey{e.user, e.deny}
+}
+
+// A run of entries next to each other in the list
+type entryBlock struct {
+	// Where the block is in the list's blocks
+	at      int
+	entries []accessControlEntry
+}
+
+// Blocks don't get smaller than this, however short the list
+const minBlockSize = 64
+
+// The entries of a list, in order. A nil list has no entries.
+type entryList struct {
+	blocks []*entryBlock
+	size   int
+	// The block each entry is in
+	index map[entryKey]*entryBlock
+	// Which entries are owners, which of those don't expire, and which
+	// are for groups. Whether an entry is an owner is worked out as it
+	// goes in, so taking it out again always undoes the count.
+	owners  map[entryKey]bool
+	lasting map[entryKey]bool
+	groups  map[entryKey]bool
+}
+
+// Make an empty list of entries
+func newEntryList() *entryList {
+	return &entryList{
+		index:   make(map[entryKey]*entryBlock),
+		owners:  make(map[entryKey]bool),
+		lasting: make(map[entryKey]bool),
+		groups:  make(map[entryKey]bool),
+	}
+}
+
+// Make a list of entries holding these, in this order
+func entryListOf(entries []accessControlEntry) *entryList {
+	l := newEntryList()
+	l.fill(entries)
+	return l
+}
+
+// Number of entries
+func (l *entryList) len() int {
+	if l == nil {
+		return 0
+	}
+	return l.size
+}
+
+// Number of entries that are owners
+func (l *entryList) ownerCount() int {
+	if l == nil {
+		return 0
+	}
+	return len(l.owners)
+}
+
+// Number of entries that are owners and don't expire
+func (l *entryList) lastingCount() int {
+	if l == nil {
+		return 0
+	}
+	return len(l.lasting)
+}
+
+// How big blocks should be for the list as long as it is
+func (l *entryList) blockSize() int {
+	size := minBlockSize
+	for size*size < l.size {
+		size *= 2
+	}
+	return size
+}
+
+// Keep track of an entry that has been put in block b
+func (l *entryList) remember(e accessControlEntry, b *entryBlock) {
+	l.index[e.key()] = b
+	if e.isOwner() {
+		l.owners[e.key()] = true
+		if e.expires.IsZero() {
+			l.lasting[e.key()] = true
+		}
+	}
+	if _, isGroup := e.group(); isGroup {
+		l.groups[e.key()] = true
+	}
+}
+
+// Stop keeping track of an entry that has been taken out
+func (l *entryList) forget(e accessControlEntry) {
+	delete(l.index, e.key())
+	delete(l.owners, e.key())
+	delete(l.lasting, e.key())
+	delete(l.groups, e.key())
+}
+
+// The block the entry at idx is in, and where in the block it is. nil
+// if idx is past the end.
+func (l *entryList) locate(idx int) (b *entryBlock, off int) {
+	for _, b := range l.blocks {
+		if idx < len(b.entries) {
+			return b, idx
+		}
+		idx -= len(b.entries)
+	}
+	return nil, 0
+}
+
+// Where the first entry of a block is in the list
+func (l *entryList) start(b *entryBlock) (idx int) {
+	for _, before := range l.blocks[:b.at] {
+		idx += len(before.entries)
+	}
+	return
+}
+
+// Where a user's entry is, or -1 if they have none
+func (l *entryList) indexOf(username string, deny bool) int {
+	if l == nil {
+		return -1
+	}
+
+	b, ok := l.index[entryKey{username, deny}]
+	if !ok {
+		return -1
+	}
+	for off, e := range b.entries {
+		if e.user == username && e.deny == deny {
+			return l.start(b) + off
+		}
+	}
+	return -1
+}
+
+// The entry at idx
+func (l *entryList) at(idx int) accessControlEntry {
+	b, off := l.locate(idx)
+	return b.entries[off]
+}
+
+// Replace the entry at idx. It may become, or stop being, an owner,
+// but stays where it is; see reorder for moving it.
+func (l *entryList) set(idx int, e accessControlEntry) {
+	b, off := l.locate(idx)
+	l.forget(b.entries[off])
+	b.entries[off] = e
+	l.remember(e, b)
+}
+
+// Put an entry at idx, moving those from there on back by one. idx
+// may be the length of the list, to add it to the end.
+func (l *entryList) insert(idx int, e accessControlEntry) {
+	if len(l.blocks) == 0 {
+		l.blocks = []*entryBlock{{at: 0}}
+	}
+
+	b, off := l.locate(idx)
+	if b == nil {
+		b = l.blocks[len(l.blocks)-1]
+		off = len(b.entries)
+	}
+
+	b.entries = append(b.entries, accessControlEntry{})
+	copy(b.entries[off+1:], b.entries[off:])
+	b.entries[off] = e
+	l.size++
+	l.remember(e, b)
+
+	if len(b.entries) > 2*l.blockSize() {
+		l.split(b)
+	}
+}
+
+// Add an entry to the end of the list
+func (l *entryList) push(e accessControlEntry) {
+	l.insert(l.size, e)
+}
+
+// Take out the entry at idx, moving those after it up by one
+func (l *entryList) remove(idx int) (e accessControlEntry) {
+	b, off := l.locate(idx)
+	e = b.entries[off]
+
+	b.entries = append(b.entries[:off], b.entries[off+1:]...)
+	l.size--
+	l.forget(e)
+
+	if len(b.entries) == 0 {
+		l.blocks = append(l.blocks[:b.at], l.blocks[b.at+1:]...)
+		l.renumber(b.at)
+	}
+	if len(l.blocks) > 4*(l.size/l.blockSize()+1) {
+		l.fill(l.all())
+	}
+	return
+}
+
+// Split a block that's grown too big into two
+func (l *entryList) split(b *entryBlock) {
+	half := len(b.entries) / 2
+	next := &entryBlock{entries: append([]accessControlEntry(nil), b.entries[half:]...)}
+	b.entries = append([]accessControlEntry(nil), b.entries[:half]...)
+
+	l.blocks = append(l.blocks, nil)
+	copy(l.blocks[b.at+2:], l.blocks[b.at+1:])
+	l.blocks[b.at+1] = next
+	l.renumber(b.at + 1)
+
+	for _, e := range next.entries {
+		l.index[e.key()] = next
+	}
+
+	// Blocks made when the list was shorter can be a lot smaller than
+	// they should be now
+	if len(l.blocks) > 4*(l.size/l.blockSize()+1) {
+		l.fill(l.all())
+	}
+}
+
+// Fix where each block from idx on thinks it is
+func (l *entryList) renumber(idx int) {
+	for ; idx < len(l.blocks); idx++ {
+		l.blocks[idx].at = idx
+	}
+}
+
+// Replace everything in the list with these entries, in even blocks
+func (l *entryList) fill(entries []accessControlEntry) {
+	l.blocks, l.size = nil, len(entries)
+	l.index = make(map[entryKey]*entryBlock, len(entries))
+	l.owners = make(map[entryKey]bool)
+	l.lasting = make(map[entryKey]bool)
+	l.groups = make(map[entryKey]bool)
+
+	size := l.blockSize()
+	for start := 0; start < len(entries); start += size {
+		end := start + size
+		if end > len(entries) {
+			end = len(entries)
+		}
+
+		b := &entryBlock{at: len(l.blocks), entries: append([]accessControlEntry(nil), entries[start:end]...)}
+		l.blocks = append(l.blocks, b)
+		for _, e := range b.entries {
+			l.remember(e, b)
+		}
+	}
+}
+
+// Every entry, in order, in a slice of its own
+func (l *entryList) all() (entries []accessControlEntry) {
+	if l == nil {
+		return nil
+	}
+
+	entries = make([]accessControlEntry, 0, l.size)
+	for _, b := range l.blocks {
+		entries = append(entries, b.entries...)
+	}
+	return
+}
+
+// The entries that could apply to a user, in order: their own, allow
+// and deny, and every group entry. Cheaper than all() when there
+// aren't many group entries.
+func (l *entryList) forUser(username string) (entries []accessControlEntry) {
+	if l == nil {
+		return nil
+	}
+
+	var idxs []int
+	for _, deny := range []bool{false, true} {
+		if idx := l.indexOf(username, deny); idx >= 0 {
+			idxs = append(idxs, idx)
+		}
+	}
+	for key := range l.groups {
+		if key.user != username {
+			idxs = append(idxs, l.indexOf(key.user, key.deny))
+		}
+	}
+	sort.Ints(idxs)
+
+	for _, idx := range idxs {
+		entries = append(entries, l.at(idx))
+	}
+	return
+}
@@ -0,0 +1,421 @@
+package acl
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEntryList(t *testing.T) {
+	// Enough entries to split blocks, and put them back together,
+	// checked against a plain slice doing the same
+	var want []accessControlEntry
+	l := newEntryList()
+
+	same := func(step int) {
+		got := l.all()
+		if len(got) != len(want) || l.len() != len(want) {
+			t.Fatalf("Fail: step %d: %d entries, want %d\n", step, len(got), len(want))
+		}
+		owners := 0
+		for idx, entry := range want {
+			if got[idx].user != entry.user || l.indexOf(entry.user, false) != idx {
+				t.Fatalf("Fail: step %d: entry %d is %s, want %s\n", step, idx, got[idx].user, entry.user)
+			}
+			if entry.isOwner() {
+				owners++
+			}
+		}
+		if l.ownerCount() != owners {
+			t.Fatalf("Fail: step %d: %d owners, want %d\n", step, l.ownerCount(), owners)
+		}
+	}
+
+	for i := 0; i < 5000; i++ {
+		entry := accessControlEntry{user: fmt.Sprintf("user%d", i), rights: R_READ}
+		if i%7 == 0 {
+			entry.rights |= R_OWN
+		}
+		idx := (i * 31) % (len(want) + 1)
+		l.insert(idx, entry)
+		want = append(want[:idx], append([]accessControlEntry{entry}, want[idx:]...)...)
+	}
+	same(0)
+
+	for i := 0; len(want) > 10; i++ {
+		idx := (i * 17) % len(want)
+		if l.remove(idx).user != want[idx].user {
+			t.Fatalf("Fail: removed the wrong entry at %d\n", idx)
+		}
+		want = append(want[:idx], want[idx+1:]...)
+		if i%500 == 0 {
+			same(i + 1)
+		}
+	}
+	same(-1)
+
+	// Groups' entries always apply, along with the user's own
+	l.fill(nil)
+	l.push(accessControlEntry{user: "@staff", rights: R_READ})
+	l.push(accessControlEntry{user: "vegdahl", rights: R_WRITE})
+	l.push(accessControlEntry{user: "root", rights: R_WRITE})
+	l.push(accessControlEntry{user: "vegdahl", rights: R_WRITE, deny: true})
+	got := l.forUser("vegdahl")
+	if len(got) != 3 || got[0].user != "@staff" || got[1].deny || !got[2].deny {
+		t.Errorf("Fail: %v\n", got)
+	}
+}
+
+// Write an ACL file with one list of n entries: every one in ownEvery
+// owns the file, the rest can read and write, and every fiftieth is
+// denied execute as well
+func writeLargeACL(b *testing.B, n, ownEvery int) (filename string) {
+	var sb strings.Builder
+	sb.WriteString(": big.c\n")
+	for i := 0; i < n; i++ {
+		if i%ownEvery == 0 {
+			fmt.Fprintf(&sb, "* user%d 15\n", i)
+		} else {
+			fmt.Fprintf(&sb, "* user%d 6\n", i)
+		}
+		if i%50 == 0 {
+			fmt.Fprintf(&sb, "- user%d x\n", i)
+		}
+	}
+
+	filename = filepath.Join(b.TempDir(), "big.txt")
+	if err := ioutil.WriteFile(filename, []byte(sb.String()), 0644); err != nil {
+		b.Fatal(err)
+	}
+	return
+}
+
+// Write a command file of n commands for the list writeLargeACL makes,
+// spread over its users: adding and deleting rights and entries, and
+// owners coming and going
+func writeLargeCommands(b *testing.B, n int) (filename string) {
+	var sb strings.Builder
+	sb.WriteString("file big.c\n")
+	for i := 0; i < n; i++ {
+		j := (i * 7919) % n
+		switch i % 6 {
+		case 0:
+			fmt.Fprintf(&sb, "ar user%d x\n", j)
+		case 1:
+			fmt.Fprintf(&sb, "dr user%d w\n", j)
+		case 2:
+			fmt.Fprintf(&sb, "ae new%d 6\n", i)
+		case 3:
+			fmt.Fprintf(&sb, "ar new%d o\n", i-1)
+		case 4:
+			fmt.Fprintf(&sb, "dr new%d o\n", i-2)
+		case 5:
+			fmt.Fprintf(&sb, "de new%d\n", i-3)
+		}
+	}
+
+	filename = filepath.Join(b.TempDir(), "commands.txt")
+	if err := ioutil.WriteFile(filename, []byte(sb.String()), 0644); err != nil {
+		b.Fatal(err)
+	}
+	return
+}
+
+// What the benchmarks need from a list's entries, so entryList can be
+// measured against the plain slice the entries used to be kept in
+type entryStorage interface {
+	len() int
+	indexOf(username string, deny bool) int
+	at(idx int) accessControlEntry
+	set(idx int, e accessControlEntry)
+	insert(idx int, e accessControlEntry)
+	push(e accessControlEntry)
+	remove(idx int) accessControlEntry
+	ownerCount() int
+	lastingCount() int
+}
+
+// The entries in one slice, as they were before entries.go: every
+// lookup and count walks the whole thing, and owners are put at the
+// front by making a new slice
+type sliceEntries struct {
+	ace []accessControlEntry
+}
+
+func (s *sliceEntries) len() int {
+	return len(s.ace)
+}
+
+func (s *sliceEntries) indexOf(username string, deny bool) int {
+	for idx, e := range s.ace {
+		if e.user == username && e.deny == deny {
+			return idx
+		}
+	}
+	return -1
+}
+
+func (s *sliceEntries) at(idx int) accessControlEntry {
+	return s.ace[idx]
+}
+
+func (s *sliceEntries) set(idx int, e accessControlEntry) {
+	s.ace[idx] = e
+}
+
+func (s *sliceEntries) insert(idx int, e accessControlEntry) {
+	if idx == 0 {
+		s.ace = append([]accessControlEntry{e}, s.ace...)
+		return
+	}
+	s.ace = append(s.ace, accessControlEntry{})
+	copy(s.ace[idx+1:], s.ace[idx:])
+	s.ace[idx] = e
+}
+
+func (s *sliceEntries) push(e accessControlEntry) {
+	s.ace = append(s.ace, e)
+}
+
+func (s *sliceEntries) remove(idx int) (e accessControlEntry) {
+	e = s.ace[idx]
+	s.ace = append(s.ace[:idx], s.ace[idx+1:]...)
+	return
+}
+
+func (s *sliceEntries) ownerCount() (n int) {
+	for _, e := range s.ace {
+		if e.isOwner() {
+			n++
+		}
+	}
+	return
+}
+
+func (s *sliceEntries) lastingCount() (n int) {
+	for _, e := range s.ace {
+		if e.isOwner() && e.expires.IsZero() {
+			n++
+		}
+	}
+	return
+}
+
+// Load entries the way a file is read: each checked for a duplicate,
+// then added to the end
+func replayLoad(b *testing.B, l entryStorage, entries []accessControlEntry) {
+	for _, e := range entries {
+		if l.indexOf(e.user, e.deny) >= 0 {
+			b.Fatalf("Fail: %s twice\n", e.user)
+		}
+		l.push(e)
+	}
+}
+
+// One command of a file writeLargeCommands made
+type largeCommand struct {
+	verb, user string
+	rights     right
+}
+
+func readLargeCommands(b *testing.B, filename string) (cmds []largeCommand) {
+	contents, err := ioutil.ReadFile(filename)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for _, line := range strings.Split(string(contents), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || fields[0] == "file" {
+			continue
+		}
+
+		cmd := largeCommand{verb: fields[0], user: fields[1]}
+		if len(fields) > 2 {
+			if cmd.rights, err = parseRights(fields[2]); err != nil {
+				b.Fatal(err)
+			}
+		}
+		cmds = append(cmds, cmd)
+	}
+	return
+}
+
+// Run commands on entries the way aclist.go does, counting the owners
+// before and after each one as the command runner does
+func replayCommands(l entryStorage, cmds []largeCommand) {
+	reorder := func(idx int) {
+		entry := l.remove(idx)
+		at := 0
+		if !entry.isOwner() {
+			at = l.ownerCount()
+		}
+		l.insert(at, entry)
+	}
+
+	for _, cmd := range cmds {
+		l.ownerCount()
+		l.lastingCount()
+
+		idx := l.indexOf(cmd.user, false)
+		switch cmd.verb {
+		case "ae":
+			if idx >= 0 {
+				break
+			}
+			entry := accessControlEntry{user: cmd.user, rights: cmd.rights}
+			if entry.isOwner() {
+				l.insert(0, entry)
+			} else {
+				l.push(entry)
+			}
+		case "de":
+			if idx >= 0 {
+				l.remove(idx)
+			}
+		case "ar", "dr":
+			if idx < 0 {
+				break
+			}
+			entry := l.at(idx)
+			changed := entry
+			if cmd.verb == "ar" {
+				changed.rights |= cmd.rights
+			} else {
+				changed.rights &= ^cmd.rights
+			}
+			l.set(idx, changed)
+			if entry.isOwner() != changed.isOwner() {
+				reorder(idx)
+			}
+		}
+
+		l.ownerCount()
+		l.lastingCount()
+	}
+}
+
+var benchSizes = []int{1000, 10000, 50000}
+
+// The entries of the list writeLargeACL makes
+func largeEntries(b *testing.B, filename string) []accessControlEntry {
+	store := newACLStore()
+	if err := store.parseInputFile(filename); err != nil {
+		b.Fatal(err)
+	}
+	return store.lists["big.c"].ace.all()
+}
+
+func BenchmarkParseLargeACL(b *testing.B) {
+	SetMessages(ioutil.Discard)
+	defer SetMessages(os.Stdout)
+
+	for _, n := range benchSizes {
+		filename := writeLargeACL(b, n, 100)
+		entries := largeEntries(b, filename)
+
+		b.Run(fmt.Sprintf("%d/store", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if err := newACLStore().parseInputFile(filename); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("%d/list", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				replayLoad(b, newEntryList(), entries)
+			}
+		})
+		b.Run(fmt.Sprintf("%d/slice", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				replayLoad(b, &sliceEntries{}, entries)
+			}
+		})
+	}
+}
+
+// Run the commands writeLargeCommands makes on the list writeLargeACL
+// makes: through a store, and straight on an entryList and on a slice.
+// The slice is left out past 10000 entries, where a run takes minutes.
+func benchCommands(b *testing.B, n, ownEvery int) {
+	aclFile, cmdFile := writeLargeACL(b, n, ownEvery), writeLargeCommands(b, n)
+	store := newACLStore()
+	if err := store.parseInputFile(aclFile); err != nil {
+		b.Fatal(err)
+	}
+	entries := largeEntries(b, aclFile)
+	cmds := readLargeCommands(b, cmdFile)
+
+	b.Run("store", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := store.runCommandFile(cmdFile, ""); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("list", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			l := entryListOf(entries)
+			b.StartTimer()
+			replayCommands(l, cmds)
+		}
+	})
+	b.Run("slice", func(b *testing.B) {
+		if n > 10000 {
+			b.Skip("too slow on a slice")
+		}
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			l := &sliceEntries{append([]accessControlEntry(nil), entries...)}
+			b.StartTimer()
+			replayCommands(l, cmds)
+		}
+	})
+}
+
+func BenchmarkRunLargeCommands(b *testing.B) {
+	SetMessages(ioutil.Discard)
+	defer SetMessages(os.Stdout)
+
+	for _, n := range benchSizes {
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			benchCommands(b, n, 100)
+		})
+	}
+}
+
+// The same 10000 entries and commands, with more and more of the users
+// owning the file
+func BenchmarkRunManyOwners(b *testing.B) {
+	SetMessages(ioutil.Discard)
+	defer SetMessages(os.Stdout)
+
+	const n = 10000
+	for _, ownEvery := range []int{100, 10, 1} {
+		b.Run(fmt.Sprint(n/ownEvery), func(b *testing.B) {
+			benchCommands(b, n, ownEvery)
+		})
+	}
+}
+
+func BenchmarkCheckLargeACL(b *testing.B) {
+	SetMessages(ioutil.Discard)
+	defer SetMessages(os.Stdout)
+
+	for _, n := range benchSizes {
+		store := newACLStore()
+		if err := store.parseInputFile(writeLargeACL(b, n, 100)); err != nil {
+			b.Fatal(err)
+		}
+
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				store.check("big.c", fmt.Sprintf("user%d", i%n), R_READ)
+			}
+		})
+	}
+}
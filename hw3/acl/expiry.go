@@ -0,0 +1,180 @@
+package acl
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The time entries are checked against. Replaced by the -now flag, so
+// the ACLs can be looked at as they were (or will be) at another time.
+// Locked, since anything running at once may ask for it.
+var clock = struct {
+	sync.RWMutex
+	now func() time.Time
+}{now: time.Now}
+
+// The time as the clock has it
+func now() time.Time {
+	clock.RLock()
+	defer clock.RUnlock()
+	return clock.now()
+}
+
+// Set the clock to f, time.Now for the real time
+func setClock(f func() time.Time) {
+	clock.Lock()
+	defer clock.Unlock()
+	clock.now = f
+}
+
+// Layouts times may be given in, with or without the time of day.
+// Times without a zone are UTC.
+var timeLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02"}
+
+// Does the entry count at time t? It doesn't before its not-before
+// time, or once it has expired.
+func (e accessControlEntry) activeAt(t time.Time) bool {
+	return !t.Before(e.notBefore) && !e.expiredAt(t)
+}
+
+// Has the entry expired by time t?
+func (e accessControlEntry) expiredAt(t time.Time) bool {
+	return !e.expires.IsZero() && !t.Before(e.expires)
+}
+
+// Are two entries the same? Times can't be compared with ==.
+func (e accessControlEntry) same(other accessControlEntry) bool {
+	return e.user == other.user && e.rights == other.rights && e.deny == other.deny &&
+		e.notBefore.Equal(other.notBefore) && e.expires.Equal(other.expires) &&
+		sameRoles(e.roles, other.roles)
+}
+
+// The time limits of an entry for printing, e.g. " until 2015-12-31T00:00:00Z".
+// Empty if it doesn't have any.
+func (e accessControlEntry) timeLimits() (str string) {
+	if !e.notBefore.IsZero() {
+		str += " from " + formatTimeLimit(e.notBefore)
+	}
+	if !e.expires.IsZero() {
+		str += " until " + formatTimeLimit(e.expires)
+	}
+	return
+}
+
+// Stringify a time limit, with no limit being empty
+func formatTimeLimit(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// Parse a time limit in any of the time layouts, with an empty string
+// being no limit
+func parseTimeLimit(str string) (t time.Time, err error) {
+	if str == "" {
+		return t, nil
+	}
+
+	for _, layout := range timeLayouts {
+		if t, err = time.Parse(layout, str); err == nil {
+			return t, nil
+		}
+	}
+
+	return t, fmt.Errorf("%q is not a time like 2015-12-31 or 2015-12-31T17:00:00Z", str)
+}
+
+// Parse when something should expire: either a time, or how long from
+// start, like '72h' or '30d'.
+func parseExpiry(str string, start time.Time) (t time.Time, err error) {
+	if t, err = parseTimeLimit(str); err == nil {
+		return t, nil
+	}
+
+	// time.ParseDuration doesn't know about days
+	if strings.HasSuffix(str, "d") {
+		if days, err := strconv.Atoi(strings.TrimSuffix(str, "d")); err == nil && days > 0 {
+			return start.AddDate(0, 0, days), nil
+		}
+	}
+
+	if d, err := time.ParseDuration(str); err == nil && d > 0 {
+		return start.Add(d), nil
+	}
+
+	return t, fmt.Errorf("%q is not a time or a duration like 72h or 30d", str)
+}
+
+// Give a user an entry with rights that last until a given time. The
+// expiry is for the whole entry, so a user who already has an entry
+// that doesn't expire can't be given one: the rights they have would
+// expire along with the new ones. An entry that already expires gets
+// the rights added, and the new expiry. ok is false if the user has an
+// entry that doesn't expire.
+func (acl *accessControlList) addTemporary(r right, username string, until time.Time) (ok bool) {
+	if !r.valid() {
+		return false
+	}
+
+	idx := acl.indexOf(username, false)
+	if idx < 0 {
+		acl.addEntry(username, r)
+		idx = acl.indexOf(username, false)
+	} else if acl.ace.at(idx).expires.IsZero() {
+		return false
+	}
+
+	entry := acl.ace.at(idx)
+	changed := entry
+	changed.rights |= r
+	changed.expires = until
+	acl.ace.set(idx, changed)
+
+	if !entry.isOwner() && changed.isOwner() {
+		acl.reorder(idx)
+	}
+	return true
+}
+
+// Remove the entries that have expired from every list the runner may
+// change, printing each one. An expired owner that is the last owner
+// of a list is kept, as the ownership rules say.
+func (cr *commandRunner) purge(cmd command) {
+	t := now()
+	var events []auditEvent
+
+	for _, filename := range cr.store.order {
+		if !cr.mayChange(filename) {
+			continue
+		}
+
+		acl := cr.store.lists[filename]
+		for idx := 0; idx < acl.ace.len(); idx++ {
+			entry := acl.ace.at(idx)
+			if !entry.expiredAt(t) {
+				continue
+			}
+
+			owners, lasting := acl.owners()
+			acl.ace.remove(idx)
+
+			if problem := cr.store.ownerProblem(acl, owners, lasting); problem != "" {
+				acl.ace.insert(idx, entry)
+				fmt.Fprintf(cr.store.messages(), "Keeping expired %s on %s: %s \n", entry.user, filename, problem)
+				continue
+			}
+
+			fmt.Fprintf(cr.store.messages(), "Purged %s (%s%s%s) from %s \n", entry.user, entry.effective(),
+				entry.roleNames(), entry.timeLimits(), filename)
+			events = append(events, auditEvent{filename: filename, user: entry.user,
+				deny: entry.deny, before: entry, beforeIdx: idx, afterIdx: -1})
+			idx--
+		}
+	}
+
+	cr.record(cmd, events...)
+}
@@ -0,0 +1,84 @@
+package acl
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestExpiry(t *testing.T) {
+	acls = newACLStore()
+	setClock(func() time.Time { return time.Date(2015, 12, 15, 0, 0, 0, 0, time.UTC) })
+	defer setClock(time.Now)
+
+	if err := acls.parseInputFile("../acl9.txt"); err != nil {
+		t.Fatal(err)
+	}
+	acl, _ := acls.lookup("main.c")
+
+	// The contractor's entry ran out on the first
+	if d := acl.check("contractor", R_WRITE); d.allowed {
+		t.Errorf("Fail: %v\n", d)
+	}
+	if d := acl.checkAt("contractor", R_WRITE, time.Date(2015, 11, 1, 0, 0, 0, 0, time.UTC)); !d.allowed {
+		t.Errorf("Fail: %v\n", d)
+	}
+	if d := acl.checkAt("intern", R_READ, time.Date(2015, 9, 1, 0, 0, 0, 0, time.UTC)); d.allowed {
+		t.Errorf("Fail: %v\n", d)
+	}
+
+	lines, _ := lexFile("../commands9.txt")
+	cmds, diags := parseCommands(flatten(lines))
+	if len(diags) > 0 {
+		t.Fatal(diags)
+	}
+	runner := newCommandRunner(acls)
+	for _, cmd := range cmds {
+		runner.run(cmd)
+	}
+
+	// temp got an entry for 30 days, and only the contractor was purged
+	temp, _ := acl.entryAt("temp", false)
+	if !temp.expires.Equal(time.Date(2016, 1, 14, 0, 0, 0, 0, time.UTC)) || acl.indexOf("contractor", false) >= 0 {
+		t.Errorf("Fail: %v\n", acl)
+	}
+
+	// Undoing the purge puts the contractor back where they were
+	runner.undo(cmds[0], 1)
+	if acl.indexOf("contractor", false) != 1 {
+		t.Errorf("Fail: %v\n", acl)
+	}
+
+	// Time limits survive being written out and read back in
+	want := acls.String()
+	str, err := acls.toACLFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	filename := filepath.Join(t.TempDir(), "acl.txt")
+	if err := writeFileAtomic(filename, []byte(str)); err != nil {
+		t.Fatal(err)
+	}
+
+	acls = newACLStore()
+	if err := acls.parseInputFile(filename); err != nil {
+		t.Fatal(err)
+	}
+	if acls.String() != want {
+		t.Errorf("Fail: %v\n%v\n", want, acls)
+	}
+
+	// Rights a user already has don't become temporary, and the last
+	// owner who doesn't expire can't give up owning the file
+	cmds, _ = parseCommands(flatten(lex("test.txt", "file main.c at crenshaw 1 1d at newbie 15 1d dr crenshaw 8")))
+	runner = newCommandRunner(acls)
+	for _, cmd := range cmds {
+		runner.run(cmd)
+	}
+	acl, _ = acls.lookup("main.c")
+	crenshaw, _ := acl.entryAt("crenshaw", false)
+	if len(runner.diags) != 2 || runner.diags[0].kind != D_DUPLICATE || runner.diags[1].kind != D_INVARIANT ||
+		!crenshaw.expires.IsZero() || !crenshaw.isOwner() || acl.indexOf("newbie", false) < 0 {
+		t.Errorf("Fail: %v\n%v\n", runner.diags, acl)
+	}
+}
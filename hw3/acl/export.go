@@ -0,0 +1,219 @@
+package acl
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// The shapes a store takes when exported to JSON or YAML. Rights are
+// written both as the bitmask and as letters; on import the letters
+// win, and the two have to agree if both are given.
+type exportEntry struct {
+	User      string   `json:"user"`
+	Rights    right    `json:"rights"`
+	Letters   string   `json:"letters"`
+	Deny      bool     `json:"deny,omitempty"`
+	NotBefore string   `json:"not_before,omitempty"`
+	Expires   string   `json:"expires,omitempty"`
+	Roles     []string `json:"roles,omitempty"`
+}
+
+type exportList struct {
+	Filename  string        `json:"filename"`
+	NoInherit bool          `json:"no_inherit,omitempty"`
+	Entries   []exportEntry `json:"entries"`
+}
+
+type exportGroup struct {
+	Name    string   `json:"name"`
+	Members []string `json:"members"`
+}
+
+type exportStore struct {
+	Files  []exportList  `json:"files"`
+	Groups []exportGroup `json:"groups,omitempty"`
+}
+
+// Formats a store can be read from or written in
+const (
+	F_TEXT = "text"
+	F_JSON = "json"
+	F_YAML = "yaml"
+)
+
+// Build the export form of a store, keeping every list's entries in
+// the order they are in.
+func (s *aclStore) export() (es exportStore) {
+	es.Files = make([]exportList, 0, len(s.order))
+
+	for _, filename := range s.order {
+		es.Files = append(es.Files, s.lists[filename].export())
+	}
+
+	for _, group := range s.groups.order {
+		es.Groups = append(es.Groups, exportGroup{group, s.groups.members[group]})
+	}
+
+	return
+}
+
+// Build the export form of a single list
+func (acl *accessControlList) export() (el exportList) {
+	el = exportList{acl.filename, acl.noInherit, make([]exportEntry, acl.ace.len())}
+
+	for idx, entry := range acl.ace.all() {
+		el.Entries[idx] = exportEntry{entry.user, entry.rights,
+			entry.rights.String(), entry.deny,
+			formatTimeLimit(entry.notBefore), formatTimeLimit(entry.expires), entry.roles}
+	}
+
+	return
+}
+
+// Load the export form of a store into s. Entries are kept in the
+// order given rather than being sorted by addEntry, so exporting and
+// importing a store gives back exactly the same store, except that an
+// owner written after someone who isn't one is moved up behind the
+// owners before it.
+func (s *aclStore) load(es exportStore) (err error) {
+	// Users the directory doesn't know, see directory.go. There are no
+	// positions to give, so they go by the file they're on.
+	var diags diagnostics
+
+	for _, group := range es.Groups {
+		s.groups.addGroup(group.Name)
+		for _, member := range group.Members {
+			s.groups.addMember(group.Name, member)
+		}
+	}
+	for _, group := range es.Groups {
+		for _, member := range group.Members {
+			s.checkKnownName(token{position{filename: groupPrefix + group.Name}, member}, &diags)
+		}
+	}
+
+	for _, el := range es.Files {
+		if el.Filename == "" {
+			return errors.New("access control list without a filename")
+		}
+
+		acl, _ := s.add(el.Filename)
+		acl.noInherit = acl.noInherit || el.NoInherit
+
+		for _, ee := range el.Entries {
+			r := ee.Rights
+
+			if ee.Letters != "" {
+				letters, err := parseLetters(ee.Letters)
+				switch {
+				case err != nil:
+					return err
+				case r != 0 && r != letters:
+					return fmt.Errorf("rights %d and letters %q "+
+						"disagree for user %s on %s", r, ee.Letters, ee.User, el.Filename)
+				}
+				r = letters
+			}
+
+			if !r.valid() {
+				return fmt.Errorf("bad rights %d for user %s on %s",
+					r, ee.User, el.Filename)
+			}
+
+			entry := accessControlEntry{user: ee.User, rights: r, deny: ee.Deny}
+			if entry.notBefore, err = parseTimeLimit(ee.NotBefore); err != nil {
+				return err
+			}
+			if entry.expires, err = parseTimeLimit(ee.Expires); err != nil {
+				return err
+			}
+			if ee.Deny && len(ee.Roles) > 0 {
+				return fmt.Errorf("deny entry for %s on %s can't hold roles",
+					ee.User, el.Filename)
+			}
+			for _, role := range ee.Roles {
+				if !roles.defined(role) {
+					return fmt.Errorf("role %s for user %s on %s isn't defined",
+						role, ee.User, el.Filename)
+				}
+			}
+			entry.roles = ee.Roles
+
+			if !acl.appendEntry(entry) {
+				return fmt.Errorf("user %s is in the list for %s twice",
+					ee.User, el.Filename)
+			}
+			// An owner that came after someone who isn't goes up to
+			// just behind the owners before it, so lists written in
+			// order stay in their order
+			if idx := acl.ace.len() - 1; entry.isOwner() && idx >= acl.ace.ownerCount() {
+				acl.ace.remove(idx)
+				acl.ace.insert(acl.ace.ownerCount(), entry)
+			}
+			s.checkKnownName(token{position{filename: el.Filename}, ee.User}, &diags)
+		}
+	}
+
+	return diags.err()
+}
+
+// Stringify a store as indented JSON
+func (s *aclStore) toJSON() (str string, err error) {
+	data, err := json.MarshalIndent(s.export(), "", "  ")
+	return string(data) + "\n", err
+}
+
+// Read a JSON file into the store
+func (s *aclStore) parseJSONFile(filename string) (err error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(s.messages(), "%s was successfully opened.\nParsing JSON from file.\n", filename)
+
+	var es exportStore
+	if err := json.Unmarshal(data, &es); err != nil {
+		return fmt.Errorf("%s: %v", filename, err)
+	}
+
+	return s.load(es)
+}
+
+// Read an ACL file in any format into the store
+func (s *aclStore) parseFileAs(filename, format string) error {
+	switch strings.ToLower(format) {
+	case F_ACL, F_TEXT:
+		return s.parseInputFile(filename)
+	case F_JSON:
+		return s.parseJSONFile(filename)
+	case F_YAML:
+		return s.parseYAMLFile(filename)
+	case F_DIR:
+		return s.parseDir(filename)
+	case F_FACL:
+		return s.parseFaclFile(filename)
+	}
+
+	return fmt.Errorf("unknown input format %q", format)
+}
+
+// Stringify the store in any format
+func (s *aclStore) format(format string) (str string, err error) {
+	switch strings.ToLower(format) {
+	case F_TEXT:
+		return s.String(), nil
+	case F_ACL:
+		return s.toACLFile()
+	case F_JSON:
+		return s.toJSON()
+	case F_YAML:
+		return s.toYAML(), nil
+	case F_FACL:
+		return s.toFacl()
+	}
+
+	return "", fmt.Errorf("unknown output format %q", format)
+}
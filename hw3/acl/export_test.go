@@ -0,0 +1,74 @@
+package acl
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestExportRoundTrip(t *testing.T) {
+	acls = newACLStore()
+
+	if err := acls.parseInputFile("../acl8.txt"); err != nil {
+		t.Fatal(err)
+	}
+	want := acls.String()
+
+	// JSON
+	str, err := acls.toJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var es exportStore
+	if err := json.Unmarshal([]byte(str), &es); err != nil {
+		t.Fatal(err)
+	}
+	acls = newACLStore()
+	if err := acls.load(es); err != nil {
+		t.Fatal(err)
+	}
+	if got := acls.String(); got != want {
+		t.Errorf("Fail: %s\n", got)
+	}
+
+	// YAML
+	value, err := parseYAML(acls.toYAML())
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := json.Marshal(value)
+	es = exportStore{}
+	if err := json.Unmarshal(data, &es); err != nil {
+		t.Fatal(err)
+	}
+	acls = newACLStore()
+	if err := acls.load(es); err != nil {
+		t.Fatal(err)
+	}
+	if got := acls.String(); got != want {
+		t.Errorf("Fail: %s\n", got)
+	}
+
+	// Owners go first, whatever order they were written in
+	es = exportStore{Files: []exportList{{Filename: "a.c", Entries: []exportEntry{
+		{User: "alice", Rights: R_OWN},
+		{User: "bob", Rights: R_READ},
+		{User: "carol", Rights: R_OWN | R_READ},
+		{User: "dave", Rights: R_WRITE},
+		{User: "erin", Rights: R_OWN},
+	}}}}
+	acls = newACLStore()
+	if err := acls.load(es); err != nil {
+		t.Fatal(err)
+	}
+	want = "printList: (File: a.c. , alice (o), carol (or), erin (o), bob (r), dave (w)) \n"
+	if got := acls.String(); got != want {
+		t.Errorf("Fail: %s\n", got)
+	}
+
+	// Letters and bitmask have to agree
+	es.Files[0].Entries[0].Letters = "r"
+	if err := newACLStore().load(es); err == nil {
+		t.Errorf("Fail: mismatched rights loaded\n")
+	}
+}
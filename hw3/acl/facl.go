@@ -0,0 +1,341 @@
+package acl
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Lists can be read from and written as the POSIX.1e ACL text that
+// 'getfacl -R' prints and 'setfacl --restore' reads:
+//
+//	# file: src/main.c
+//	# owner: crenshaw
+//	# group: staff
+//	user::rw-
+//	user:vegdahl:r--
+//	group::r--
+//	mask::r--
+//	other::---
+//
+// The entries map to a list the same way mode bits do (see posix.go):
+// 'user::' is the owner's entry, and has the own right too, 'group::'
+// is the entry for the file's group (e.g. '@staff'), and 'other::' is
+// the entry for 'other'. Named users and groups are plain and group
+// entries.
+//
+// The mask limits everything but the owner and other, so it's applied
+// when reading and worked out again when writing. Default entries are
+// for directories to hand down to new files, which lists don't do, so
+// they're skipped.
+
+// Format of getfacl text
+const F_FACL = "facl"
+
+// Parse the permissions of a getfacl entry, like 'r-x'
+func parseFaclPerms(str string) (r right, err error) {
+	for _, c := range str {
+		switch c {
+		case 'r':
+			r |= R_READ
+		case 'w':
+			r |= R_WRITE
+		case 'x':
+			r |= R_EXEC
+		case '-':
+		default:
+			return 0, fmt.Errorf("%q is not permissions like r-x", str)
+		}
+	}
+	return r, nil
+}
+
+// Stringify rights the way getfacl does, like 'r-x'
+func faclPerms(r right) string {
+	perms := []byte("---")
+	if r&R_READ != 0 {
+		perms[0] = 'r'
+	}
+	if r&R_WRITE != 0 {
+		perms[1] = 'w'
+	}
+	if r&R_EXEC != 0 {
+		perms[2] = 'x'
+	}
+	return string(perms)
+}
+
+// getfacl writes white space and backslashes in names as octal escapes
+// like '\040', so that names are always one word
+func escapeFacl(name string) (str string) {
+	for _, b := range []byte(name) {
+		if b <= ' ' || b == '\\' || b >= 0x7f {
+			str += fmt.Sprintf("\\%03o", b)
+		} else {
+			str += string(b)
+		}
+	}
+	return
+}
+
+func unescapeFacl(str string) (name string, err error) {
+	var buf []byte
+	for idx := 0; idx < len(str); idx++ {
+		if str[idx] != '\\' {
+			buf = append(buf, str[idx])
+			continue
+		}
+
+		if idx+4 > len(str) {
+			return "", fmt.Errorf("bad escape in %q", str)
+		}
+		b, err := strconv.ParseUint(str[idx+1:idx+4], 8, 8)
+		if err != nil {
+			return "", fmt.Errorf("bad escape in %q", str)
+		}
+		buf = append(buf, byte(b))
+		idx += 3
+	}
+	return string(buf), nil
+}
+
+// One file's worth of getfacl text, as it's read
+type faclBlock struct {
+	file         token
+	owner, group string
+	users        []accessControlEntry
+	groups       []accessControlEntry
+	ownerPerms   right
+	groupPerms   right
+	otherPerms   right
+	mask         right
+	hasMask      bool
+}
+
+// Add the list for a block to the store. The mask is applied to the
+// named users and groups and the file's group.
+func (b *faclBlock) add(s *aclStore, diags *diagnostics) {
+	if b.owner == "" {
+		diags.add(b.file, D_SYNTAX, "no '# owner:' line for file, so 'user::' has nobody to go to")
+		return
+	}
+
+	acl, ok := s.add(b.file.text)
+	if !ok {
+		diags.add(b.file, D_DUPLICATE, "file is in the getfacl text twice")
+		return
+	}
+
+	// A block is all there is to a file's access, whatever the
+	// directories above it say (see inherit.go)
+	acl.noInherit = true
+
+	limit := func(r right) right {
+		if b.hasMask {
+			return r & b.mask
+		}
+		return r
+	}
+
+	acl.addEntry(b.owner, R_OWN|b.ownerPerms)
+	for _, entry := range b.users {
+		acl.addEntry(entry.user, limit(entry.rights))
+	}
+	if b.group != "" {
+		acl.addEntry(groupPrefix+b.group, limit(b.groupPerms))
+	}
+	for _, entry := range b.groups {
+		acl.addEntry(entry.user, limit(entry.rights))
+	}
+	acl.addEntry(posixOther, b.otherPerms)
+}
+
+// Read getfacl text into the store, one list for each '# file:'
+func (s *aclStore) parseFaclFile(filename string) (err error) {
+	lines, err := lexFile(filename)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(s.messages(), "%s was successfully opened.\nParsing getfacl text from file.\n", filename)
+
+	var diags diagnostics
+	var block *faclBlock
+
+	for _, line := range lines {
+		// A blank line ends a file's entries
+		if len(line) == 0 {
+			if block != nil {
+				block.add(s, &diags)
+				block = nil
+			}
+			continue
+		}
+
+		// Comments hold the file, owner and group
+		if line[0].text == "#" {
+			if len(line) < 3 {
+				continue
+			}
+			value, err := unescapeFacl(line[2].text)
+			if err != nil {
+				diags.add(line[2], D_SYNTAX, "%v", err)
+				continue
+			}
+
+			switch line[1].text {
+			case "file:":
+				if block != nil {
+					block.add(s, &diags)
+				}
+				file := line[2]
+				file.text = value
+				block = &faclBlock{file: file}
+			case "owner:":
+				if block != nil {
+					block.owner = value
+				}
+			case "group:":
+				if block != nil {
+					block.group = value
+				}
+			}
+			continue
+		}
+
+		if block == nil {
+			diags.add(line[0], D_SYNTAX, "entry comes before any '# file:' line")
+			continue
+		}
+
+		// Anything after the entry is an '#effective:' comment
+		parts := strings.Split(line[0].text, ":")
+		if parts[0] == "default" || parts[0] == "d" {
+			continue
+		}
+		if len(parts) != 3 {
+			diags.add(line[0], D_SYNTAX, "expected an entry like user:name:rwx")
+			continue
+		}
+
+		r, err := parseFaclPerms(parts[2])
+		if err != nil {
+			diags.add(line[0], D_BAD_RIGHT, "%v", err)
+			continue
+		}
+		name, err := unescapeFacl(parts[1])
+		if err != nil {
+			diags.add(line[0], D_SYNTAX, "%v", err)
+			continue
+		}
+
+		switch {
+		case (parts[0] == "user" || parts[0] == "u") && name == "":
+			block.ownerPerms = r
+		case parts[0] == "user" || parts[0] == "u":
+			block.users = append(block.users, accessControlEntry{user: name, rights: r})
+		case (parts[0] == "group" || parts[0] == "g") && name == "":
+			block.groupPerms = r
+		case parts[0] == "group" || parts[0] == "g":
+			block.groups = append(block.groups, accessControlEntry{user: groupPrefix + name, rights: r})
+		case parts[0] == "mask" || parts[0] == "m":
+			block.mask, block.hasMask = r, true
+		case parts[0] == "other" || parts[0] == "o":
+			block.otherPerms = r
+		default:
+			diags.add(line[0], D_SYNTAX, "not a user, group, mask or other entry")
+		}
+	}
+
+	if block != nil {
+		block.add(s, &diags)
+	}
+
+	return diags.err()
+}
+
+// Stringify a store as getfacl text. The file's owner is its first
+// owner and its group is its first group entry. Anything getfacl text
+// can't hold (patterns, deny entries, time limits, more than one owner
+// and rights other than read, write and exec) is an error rather than
+// being quietly left out.
+func (s *aclStore) toFacl() (str string, err error) {
+	for _, filename := range s.order {
+		acl := s.lists[filename]
+		if isPattern(filename) {
+			return "", fmt.Errorf("%s is a pattern, not a file", filename)
+		}
+
+		var owner, group *accessControlEntry
+		var users, groups []accessControlEntry
+		var other right
+		var mask right
+
+		for _, expanded := range acl.ace.all() {
+			// getfacl text has no roles, just what they add up to
+			expanded.rights, expanded.roles = expanded.effective(), nil
+			entry := &expanded
+			_, isGroup := entry.group()
+
+			switch {
+			case entry.deny:
+				return "", fmt.Errorf("deny entry for %s on %s can't be written as getfacl text",
+					entry.user, filename)
+			case !entry.notBefore.IsZero() || !entry.expires.IsZero():
+				return "", fmt.Errorf("time limits for %s on %s can't be written as getfacl text",
+					entry.user, filename)
+			case entry.rights&^(posixRights|R_OWN) != 0 || (isGroup && entry.rights&R_OWN != 0):
+				return "", fmt.Errorf("rights %s for %s on %s can't be written as getfacl text",
+					entry.rights, entry.user, filename)
+			}
+
+			switch {
+			case entry.isOwner() && owner != nil:
+				return "", fmt.Errorf("%s has more than one owner, getfacl text can only have one",
+					filename)
+			case entry.isOwner():
+				owner = entry
+			case entry.user == posixOther:
+				other = entry.rights
+			case isGroup && group == nil:
+				group = entry
+				mask |= entry.rights
+			case isGroup:
+				groups = append(groups, *entry)
+				mask |= entry.rights
+			default:
+				users = append(users, *entry)
+				mask |= entry.rights
+			}
+		}
+
+		if owner == nil {
+			return "", fmt.Errorf("%s has no owner, getfacl text has to have one", filename)
+		}
+
+		str += fmt.Sprintf("# file: %s\n# owner: %s\n", escapeFacl(filename), escapeFacl(owner.user))
+		groupPerms := right(0)
+		if group != nil {
+			name, _ := group.group()
+			str += fmt.Sprintf("# group: %s\n", escapeFacl(name))
+			groupPerms = group.rights
+		}
+
+		str += fmt.Sprintf("user::%s\n", faclPerms(owner.rights))
+		for _, entry := range users {
+			str += fmt.Sprintf("user:%s:%s\n", escapeFacl(entry.user), faclPerms(entry.rights))
+		}
+		str += fmt.Sprintf("group::%s\n", faclPerms(groupPerms))
+		for _, entry := range groups {
+			name, _ := entry.group()
+			str += fmt.Sprintf("group:%s:%s\n", escapeFacl(name), faclPerms(entry.rights))
+		}
+
+		// A mask is only needed with named users or groups
+		if len(users) > 0 || len(groups) > 0 {
+			str += fmt.Sprintf("mask::%s\n", faclPerms(mask))
+		}
+		str += fmt.Sprintf("other::%s\n\n", faclPerms(other))
+	}
+
+	return
+}
@@ -0,0 +1,37 @@
+package acl
+
+import (
+	"io/ioutil"
+	"testing"
+)
+
+func TestFacl(t *testing.T) {
+	acls = newACLStore()
+
+	// facl2.txt is facl1.txt with the mask applied and the default
+	// entries and flags left out
+	if err := acls.parseFaclFile("../facl1.txt"); err != nil {
+		t.Fatal(err)
+	}
+	want, err := ioutil.ReadFile("../facl2.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if str, err := acls.toFacl(); err != nil || str != string(want) {
+		t.Errorf("Fail: %v\n%s\n", err, str)
+	}
+
+	if d := acls.check("src/main.c", "vegdahl", R_WRITE); d.allowed {
+		t.Errorf("Fail: %v\n", d)
+	}
+	if _, ok := acls.lookup("src/my notes.txt"); !ok {
+		t.Errorf("Fail: %v\n", acls)
+	}
+
+	// Deny entries can't be written as getfacl text
+	acl, _ := acls.lookup("src/main.c")
+	acl.addDenyEntry("mallory", R_READ)
+	if _, err := acls.toFacl(); err == nil {
+		t.Errorf("Fail: wrote a deny entry\n")
+	}
+}
@@ -0,0 +1,208 @@
+package acl
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// A list's filename may be a glob pattern instead of a single file,
+// e.g. ': *.c' or ': src/**/*.go'. Patterns are matched a path
+// segment at a time, as path.Match does it, except that a '**'
+// segment matches any number of segments, none included. A '*' never
+// matches a '/', so '*.c' is only for files at the top; use '**/*.c'
+// for them all.
+//
+// Any number of lists may apply to a path: its own list, if it has
+// one, every pattern that matches it, and the lists of the directories
+// above it (see inherit.go). They're merged by specificity, most
+// specific first:
+//
+//  1. The path's own list is more specific than any pattern.
+//  2. A pattern with more literal characters (those that aren't part
+//     of a '*', '?', '**' or '[...]') is more specific.
+//  3. Then the pattern with fewer wildcards.
+//  4. Then the pattern further down the file, so later rules override
+//     earlier ones.
+//  5. Last come the directories' lists, nearest first.
+//
+// Each user gets the entry of the most specific list that has one for
+// them. Deny entries are kept apart from allow entries, so a general
+// rule's deny still holds unless a more specific one has its own deny
+// entry for the user (which may deny nothing at all).
+
+// Is a filename a pattern rather than a single file?
+func isPattern(name string) bool {
+	return strings.ContainsAny(name, "*?[")
+}
+
+// Does a path match a pattern? A malformed pattern matches nothing.
+func matchGlob(pattern, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			// Try matching the rest from every segment onwards
+			for skip := 0; skip <= len(name); skip++ {
+				if matchSegments(pattern[1:], name[skip:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(name) == 0 {
+			return false
+		}
+		if ok, err := path.Match(pattern[0], name[0]); !ok || err != nil {
+			return false
+		}
+
+		pattern, name = pattern[1:], name[1:]
+	}
+
+	return len(name) == 0
+}
+
+// How specific a pattern is: the number of literal characters in it,
+// and the number of wildcards
+func specificity(pattern string) (literals, wildcards int) {
+	inClass := false
+	for idx, r := range pattern {
+		switch {
+		case inClass:
+			if r == ']' {
+				inClass = false
+			}
+		case r == '[':
+			inClass = true
+			wildcards++
+		case r == '*':
+			// '**' is a single wildcard
+			if idx == 0 || pattern[idx-1] != '*' {
+				wildcards++
+			}
+		case r == '?':
+			wildcards++
+		default:
+			literals++
+		}
+	}
+	return
+}
+
+// Every list that applies to a path, most specific first. ok is false
+// if none do.
+func (s *aclStore) rulesFor(name string) (rules []*accessControlList, ok bool) {
+	// Indexes into the store's order, to break ties with
+	order := make(map[*accessControlList]int)
+
+	for idx, filename := range s.order {
+		if filename == name || (isPattern(filename) && matchGlob(filename, name)) {
+			rules = append(rules, s.lists[filename])
+			order[s.lists[filename]] = idx
+		}
+	}
+
+	sort.SliceStable(rules, func(i, j int) bool {
+		a, b := rules[i].filename, rules[j].filename
+		if (a == name) != (b == name) {
+			return a == name
+		}
+
+		aLiterals, aWildcards := specificity(a)
+		bLiterals, bWildcards := specificity(b)
+		switch {
+		case aLiterals != bLiterals:
+			return aLiterals > bLiterals
+		case aWildcards != bWildcards:
+			return aWildcards < bWildcards
+		}
+		return order[rules[i]] > order[rules[j]]
+	})
+
+	rules = append(rules, s.inherited(name)...)
+	return rules, len(rules) > 0
+}
+
+// The list that applies to a path: every list that applies to it,
+// merged as described above. Owners are moved to the front, as they
+// are in any other list. ok is false if no list applies.
+func (s *aclStore) resolve(name string) (acl *accessControlList, ok bool) {
+	// A file with only its own list needs nothing merged, which saves
+	// copying a long one for every check. Its owners are already at
+	// the front. (A list that blocks inheriting still goes through the
+	// merge, which leaves that out, as it always has.)
+	if rules, found := s.rulesFor(name); found && len(rules) == 1 && rules[0].filename == name &&
+		!rules[0].noInherit {
+		return rules[0], true
+	}
+
+	acl, _, ok = s.resolveFrom(name)
+	return
+}
+
+// Same as resolve, but also gives the filename of the list each entry
+// came from, in the same order as the entries
+func (s *aclStore) resolveFrom(name string) (acl *accessControlList, from []string, ok bool) {
+	rules, ok := s.rulesFor(name)
+	if !ok {
+		return nil, nil, false
+	}
+
+	acl = new(accessControlList)
+	acl.initialize(name)
+	acl.groups = s.groups
+
+	// Users (and whether it's their deny entry) that have an entry already
+	type key struct {
+		user string
+		deny bool
+	}
+	seen := make(map[key]bool)
+
+	var others []accessControlEntry
+	var othersFrom []string
+	for _, rule := range rules {
+		for _, entry := range rule.ace.all() {
+			if seen[key{entry.user, entry.deny}] {
+				continue
+			}
+			seen[key{entry.user, entry.deny}] = true
+
+			if entry.isOwner() {
+				acl.ace.push(entry)
+				from = append(from, rule.filename)
+			} else {
+				others = append(others, entry)
+				othersFrom = append(othersFrom, rule.filename)
+			}
+		}
+	}
+	for _, entry := range others {
+		acl.ace.push(entry)
+	}
+	from = append(from, othersFrom...)
+
+	return acl, from, true
+}
+
+// Print which lists apply to a path, and the list they make together
+func (s *aclStore) printRules(name string) (ok bool) {
+	rules, ok := s.rulesFor(name)
+	if !ok {
+		return false
+	}
+
+	names := make([]string, 0, len(rules))
+	for _, rule := range rules {
+		names = append(names, rule.filename)
+	}
+	acl, _ := s.resolve(name)
+
+	fmt.Fprintf(s.messages(), "Rules for %s: %s \n%v", name, strings.Join(names, ", "), acl)
+	return true
+}
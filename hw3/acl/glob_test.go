@@ -0,0 +1,54 @@
+package acl
+
+import (
+	"testing"
+)
+
+func TestPatterns(t *testing.T) {
+	acls = newACLStore()
+
+	if err := acls.parseInputFile("../acl10.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		pattern, name string
+		match         bool
+	}{
+		{"**", "a/b/c", true},
+		{"*.c", "main.c", true},
+		{"*.c", "src/main.c", false},
+		{"src/**/*.go", "src/main.go", true},
+		{"src/**/*.go", "src/a/b/main.go", true},
+		{"src/**/*.go", "lib/main.go", false},
+		{"[", "[", false},
+	} {
+		if matchGlob(c.pattern, c.name) != c.match {
+			t.Errorf("Fail: %s matching %s\n", c.pattern, c.name)
+		}
+	}
+
+	// The file's own list, then the longer pattern, then the catch-all
+	rules, _ := acls.rulesFor("src/lib/util.go")
+	if len(rules) != 3 || rules[0].filename != "src/lib/util.go" || rules[2].filename != "**" {
+		t.Errorf("Fail: %v\n", rules)
+	}
+
+	// gopher's entry comes from the file's own list, and the deny
+	// from the catch-all still holds for the intern
+	if d := acls.check("src/lib/util.go", "gopher", R_WRITE); d.allowed {
+		t.Errorf("Fail: %v\n", d)
+	}
+	if d := acls.check("src/cmd/main.go", "gopher", R_WRITE); !d.allowed {
+		t.Errorf("Fail: %v\n", d)
+	}
+	if d := acls.check("src/cmd/main.go", "intern", R_WRITE); d.allowed {
+		t.Errorf("Fail: %v\n", d)
+	}
+
+	// Owners still come first
+	acl, _ := acls.resolve("main.c")
+	if acl.ace.at(0).user != "crenshaw" || acl.ace.at(1).user != "root" {
+		t.Errorf("Fail: %v\n", acl)
+	}
+}
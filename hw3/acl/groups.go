@@ -0,0 +1,98 @@
+package acl
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Entries for a group instead of a single user have their name
+// prefixed with this, e.g. "* @ops" in an ACL file.
+const groupPrefix = "@"
+
+// A groupTable maps group names to their members. Groups are shared
+// by every ACL in a store.
+type groupTable struct {
+	members map[string][]string
+	order   []string
+}
+
+// Make a new, empty group table
+func newGroupTable() *groupTable {
+	return &groupTable{
+		members: make(map[string][]string),
+		order:   make([]string, 0),
+	}
+}
+
+// Name of the group an entry is for, ok is false if the entry
+// is for a plain user.
+func (e accessControlEntry) group() (name string, ok bool) {
+	if strings.HasPrefix(e.user, groupPrefix) {
+		return strings.TrimPrefix(e.user, groupPrefix), true
+	}
+	return "", false
+}
+
+// Make sure a group exists, even with no members
+func (g *groupTable) addGroup(group string) {
+	if _, found := g.members[group]; !found {
+		g.members[group] = make([]string, 0)
+		g.order = append(g.order, group)
+	}
+}
+
+// Add a user to a group, creating the group if needed.
+// ok is false if the user was already a member.
+func (g *groupTable) addMember(group, user string) (ok bool) {
+	g.addGroup(group)
+
+	if g.isMember(group, user) {
+		return false
+	}
+
+	g.members[group] = append(g.members[group], user)
+	return true
+}
+
+// Remove a user from a group.
+// ok is false if they weren't a member to begin with.
+func (g *groupTable) removeMember(group, user string) (ok bool) {
+	for idx, member := range g.members[group] {
+		if member == user {
+			g.members[group] = append(g.members[group][:idx],
+				g.members[group][idx+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
+// Is the user in the group?
+func (g *groupTable) isMember(group, user string) bool {
+	// A nil table has no groups at all
+	if g == nil {
+		return false
+	}
+
+	for _, member := range g.members[group] {
+		if member == user {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Stringify the groups and their members
+func (g *groupTable) String() (str string) {
+	str = fmt.Sprintf("printGroups: (")
+
+	for _, group := range g.order {
+		str += fmt.Sprintf(", %s: %s", group, strings.Join(g.members[group], " "))
+	}
+
+	str += fmt.Sprintf(") \n")
+
+	return
+}
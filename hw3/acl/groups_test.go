@@ -0,0 +1,31 @@
+package acl
+
+import (
+	"testing"
+)
+
+func TestGroups(t *testing.T) {
+	acls = newACLStore()
+
+	if err := acls.parseGroupFile("../groups1.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if err := acls.parseInputFile("../acl5.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Direct and group rights are unioned
+	if d := acls.check("main.c", "vegdahl", R_READ|R_WRITE|R_EXEC); !d.allowed || len(d.entries) != 2 {
+		t.Errorf("Fail: %v\n", d)
+	}
+
+	// Groups from the separate file count too
+	if d := acls.check("main.c", "bob", R_READ); !d.allowed {
+		t.Errorf("Fail: %v\n", d)
+	}
+
+	acls.groups.removeMember("ops", "ubuntu")
+	if d := acls.check("main.c", "ubuntu", R_READ); d.allowed {
+		t.Errorf("Fail: %v\n", d)
+	}
+}
@@ -0,0 +1,261 @@
+package acl
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// An auditEvent records one change made to a store: to a user's entry
+// in a list, to a group's members, or to whether a list inherits.
+// Where the entry was in the list before and after is kept (-1 if it
+// wasn't there), so the change can be undone or redone exactly.
+type auditEvent struct {
+	when time.Time
+	// The command that made the change
+	pos  position
+	verb string
+	// The list changed, or the group for membership changes
+	filename string
+	group    string
+	user     string
+	deny     bool
+	// A change to the list's inheritance rather than to an entry
+	inherit bool
+	// The entry and its position, group membership (0 for a member),
+	// or inheritance (0 for blocked, see inheritIdx)
+	before, after       accessControlEntry
+	beforeIdx, afterIdx int
+}
+
+// e.g. "2015-10-18T14:03:00Z commands1.txt:1:1 dr main.c vegdahl rw -> w"
+func (e auditEvent) String() string {
+	target := e.filename
+	if e.group != "" {
+		target = "group " + e.group
+	}
+
+	user := e.user
+	if e.deny {
+		user = "deny " + user
+	}
+	if e.inherit {
+		user = "inheritance"
+	}
+
+	return fmt.Sprintf("%s %v %s %s %s %s -> %s", e.when.UTC().Format(time.RFC3339),
+		e.pos, e.verb, target, user, e.describe(e.before, e.beforeIdx),
+		e.describe(e.after, e.afterIdx))
+}
+
+// How the entry or membership looked on one side of the change. The
+// rights are the ones the entry gives, roles included, which is what
+// the change did to the user; the roles are named after them.
+func (e auditEvent) describe(entry accessControlEntry, idx int) string {
+	switch {
+	case e.inherit && idx < 0:
+		return "inherits"
+	case e.inherit:
+		return "blocked"
+	case idx < 0 && e.group != "":
+		return "not-member"
+	case idx < 0:
+		return "no-entry"
+	case e.group != "":
+		return "member"
+	case entry.effective() == 0:
+		return "none" + entry.roleNames() + entry.timeLimits()
+	}
+	return entry.effective().String() + entry.roleNames() + entry.timeLimits()
+}
+
+// The same change, backwards
+func (e auditEvent) reverse() auditEvent {
+	e.before, e.after = e.after, e.before
+	e.beforeIdx, e.afterIdx = e.afterIdx, e.beforeIdx
+	return e
+}
+
+// Make the change an event describes to a store, which has to be in
+// the state the event started from.
+func (e auditEvent) apply(store *aclStore) {
+	if e.group != "" {
+		if e.afterIdx < 0 {
+			store.groups.removeMember(e.group, e.user)
+		} else {
+			store.groups.addMember(e.group, e.user)
+		}
+		return
+	}
+
+	acl, ok := store.lookup(e.filename)
+	if !ok {
+		return
+	}
+
+	if e.inherit {
+		acl.setInherit(e.afterIdx < 0)
+		return
+	}
+
+	if e.beforeIdx >= 0 {
+		acl.ace.remove(e.beforeIdx)
+	}
+
+	if e.afterIdx >= 0 {
+		acl.entries().insert(e.afterIdx, e.after)
+	}
+}
+
+// Where a user's entry is in a list, or -1 if they have none
+func (acl *accessControlList) indexOf(username string, deny bool) int {
+	return acl.ace.indexOf(username, deny)
+}
+
+// A user's entry and where it is in a list, with -1 for where if they
+// have no entry
+func (acl *accessControlList) entryAt(username string, deny bool) (entry accessControlEntry, idx int) {
+	idx = acl.indexOf(username, deny)
+	if idx >= 0 {
+		entry = acl.ace.at(idx)
+	}
+	return
+}
+
+// Whether a list inherits, the way an auditEvent keeps it: -1 if it
+// does, 0 if it's blocked
+func (acl *accessControlList) inheritIdx() int {
+	if acl.noInherit {
+		return 0
+	}
+	return -1
+}
+
+// Where a user is in a group's members, or -1 if they aren't in it
+func (g *groupTable) indexOf(group, user string) int {
+	for idx, member := range g.members[group] {
+		if member == user {
+			return idx
+		}
+	}
+	return -1
+}
+
+// A change is everything one command did, which is usually a single
+// event, but can be more (purge removes many entries at once). Undo
+// and redo work on whole changes.
+type change []auditEvent
+
+// May the user commands are run as make (or take back) all of the
+// changes? Changing groups takes an administrator, as with ga and gr.
+func (cr *commandRunner) mayChangeAll(changes []change) bool {
+	for _, events := range changes {
+		for _, event := range events {
+			if (event.group != "" && cr.principal != "") || !cr.mayChange(event.filename) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Undo the last n changes, most recent first. ok is false if there
+// weren't that many to undo, or the user commands are run as may not
+// undo them, in which case nothing is undone.
+//
+// Only changes this runner made can be undone, that is, those of the
+// same command file or shell session. The audit log is a record, not a
+// history to go back through: changes from earlier runs are already in
+// the lists that were written out.
+//
+// Undoing only ever goes back to how things were, so the ownership
+// rules aren't checked again.
+func (cr *commandRunner) undo(cmd command, n int) (ok bool) {
+	if n > len(cr.done) || !cr.mayChangeAll(cr.done[len(cr.done)-n:]) {
+		return false
+	}
+
+	for ; n > 0; n-- {
+		events := cr.done[len(cr.done)-1]
+		cr.done = cr.done[:len(cr.done)-1]
+
+		// Last event first, so positions line up again
+		for idx := len(events) - 1; idx >= 0; idx-- {
+			events[idx].reverse().apply(cr.store)
+			cr.logEvent(cmd, events[idx].reverse())
+		}
+		cr.undone = append(cr.undone, events)
+	}
+
+	return true
+}
+
+// Redo the last n changes undone. ok is false if there weren't that
+// many to redo, or the user commands are run as may not redo them, in
+// which case nothing is redone.
+func (cr *commandRunner) redo(cmd command, n int) (ok bool) {
+	if n > len(cr.undone) || !cr.mayChangeAll(cr.undone[len(cr.undone)-n:]) {
+		return false
+	}
+
+	for ; n > 0; n-- {
+		events := cr.undone[len(cr.undone)-1]
+		cr.undone = cr.undone[:len(cr.undone)-1]
+
+		for _, event := range events {
+			event.apply(cr.store)
+			cr.logEvent(cmd, event)
+		}
+		cr.done = append(cr.done, events)
+	}
+
+	return true
+}
+
+// Record the change a command made, so it can be undone. Events that
+// didn't change anything aren't worth recording.
+func (cr *commandRunner) record(cmd command, events ...auditEvent) {
+	var recorded change
+	for _, event := range events {
+		if event.before.same(event.after) && event.beforeIdx == event.afterIdx {
+			continue
+		}
+		recorded = append(recorded, cr.logEvent(cmd, event))
+	}
+
+	if len(recorded) == 0 {
+		return
+	}
+	cr.done = append(cr.done, recorded)
+
+	// A new change means there's nothing to redo anymore
+	cr.undone = cr.undone[:0]
+}
+
+// Add an event to the audit log, stamped with the command and time
+func (cr *commandRunner) logEvent(cmd command, event auditEvent) auditEvent {
+	event.when = now()
+	event.pos = cmd.verb.pos
+	event.verb = cmd.verb.text
+
+	cr.log = append(cr.log, event)
+	return event
+}
+
+// Append events to the audit log file, creating it if needed. The
+// file is only ever added to, never rewritten.
+func writeAuditLog(filename string, events []auditEvent) (err error) {
+	file, err := os.OpenFile(filename, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	for _, event := range events {
+		if _, err := fmt.Fprintf(file, "%v\n", event); err != nil {
+			return err
+		}
+	}
+
+	return file.Sync()
+}
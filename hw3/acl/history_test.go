@@ -0,0 +1,43 @@
+package acl
+
+import (
+	"testing"
+)
+
+func TestUndoRedo(t *testing.T) {
+	acls = newACLStore()
+
+	if err := acls.parseInputFile("../acl1.txt"); err != nil {
+		t.Fatal(err)
+	}
+	before := acls.String()
+
+	runner := newCommandRunner(acls)
+	cmds, diags := parseCommands(flatten(lex("test.txt",
+		"de vegdahl\nar ubuntu 8\ndr crenshaw 8\nar ubuntu 8\nundo 3")))
+	if len(diags) > 0 {
+		t.Fatal(diags)
+	}
+	for _, cmd := range cmds {
+		runner.run(cmd)
+	}
+
+	// The second 'ar ubuntu 8' changed nothing, so isn't a change
+	if len(runner.diags) > 0 || acls.String() != before {
+		t.Errorf("Fail: %v\n%v\n", runner.diags, acls)
+	}
+	if len(runner.log) != 6 || runner.log[3].verb != "undo" {
+		t.Errorf("Fail: %v\n", runner.log)
+	}
+
+	runner.redo(cmds[0], 3)
+	mainC, _ := acls.lookup("main.c")
+	if mainC.ace.len() != 3 || mainC.ace.at(0).user != "ubuntu" || mainC.ace.at(1).rights != R_READ|R_WRITE|R_EXEC {
+		t.Errorf("Fail: %v\n", mainC)
+	}
+
+	// Can't undo more than was done
+	if runner.undo(cmds[0], 4) {
+		t.Errorf("Fail: undid 4\n")
+	}
+}
@@ -0,0 +1,100 @@
+package acl
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// A list for a directory is inherited by everything under it, so
+// ': src/' (or ': src') with an entry for vegdahl gives vegdahl the
+// same on src/lib/util.go, unless something closer says otherwise.
+// Inherited lists come after the path's own list and the patterns
+// that match it, nearest directory first, and are merged the same
+// way (see glob.go): the closest entry for a user overrides the ones
+// further up.
+//
+// A list can block inheritance, with 'noinherit' after its filename,
+// ': vendor/ noinherit', or the 'block' command. Nothing above it is
+// inherited then, by its own path or anything under it. 'inherit'
+// undoes that. Patterns aren't inherited, they always apply to the
+// paths they match directly, and blocking doesn't stop them.
+//
+// Ownership is inherited like any other right, so the owner of src/
+// may change the lists of everything under it, as 'as' has them do
+// (see owners.go), unless a closer list takes the ownership away or
+// blocks inheritance. An owner of src/ doesn't own src/vendor/ once
+// it's blocked, so can't unblock it either.
+//
+// Relative paths have '.' (or './') as their top directory, and
+// absolute ones '/'.
+
+// The word after a filename that blocks inheritance
+const noInherit = "noinherit"
+
+// The lists of the directories above a path, nearest first, up to the
+// first that blocks inheritance. None if the path's own list blocks it.
+func (s *aclStore) inherited(name string) (lists []*accessControlList) {
+	if own, ok := s.lists[name]; ok && own.noInherit {
+		return nil
+	}
+
+	dir := strings.TrimSuffix(name, "/")
+	for dir != "." && dir != "/" && dir != "" {
+		dir = path.Dir(dir)
+
+		blocked := false
+		for _, candidate := range []string{dir, strings.TrimSuffix(dir, "/") + "/"} {
+			if acl, ok := s.lists[candidate]; ok && candidate != name {
+				lists = append(lists, acl)
+				blocked = blocked || acl.noInherit
+			}
+			if dir == "/" {
+				break
+			}
+		}
+		if blocked {
+			break
+		}
+	}
+
+	return
+}
+
+// Block inheritance for a list, or let it inherit again. ok is false
+// if it already was that way.
+func (acl *accessControlList) setInherit(inherit bool) (ok bool) {
+	if acl.noInherit == !inherit {
+		return false
+	}
+	acl.noInherit = !inherit
+	return true
+}
+
+// Print the list that applies to a path, with where each entry in it
+// came from: the path's own list, a pattern, or a directory above it
+func (s *aclStore) printEffective(name string) (ok bool) {
+	acl, from, ok := s.resolveFrom(name)
+	if !ok {
+		return false
+	}
+
+	fmt.Fprintf(s.messages(), "Effective list for %s: \n", name)
+	if acl.ace.len() == 0 {
+		fmt.Fprintf(s.messages(), "  No entries.\n")
+	}
+	for idx, entry := range acl.ace.all() {
+		user := entry.user
+		if entry.deny {
+			user = "deny " + user
+		}
+
+		source := "own list"
+		if from[idx] != name {
+			source = "from " + from[idx]
+		}
+		fmt.Fprintf(s.messages(), "  %s (%s%s%s) %s\n", user, entry.effective(), entry.roleNames(),
+			entry.timeLimits(), source)
+	}
+	return true
+}
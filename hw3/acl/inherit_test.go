@@ -0,0 +1,99 @@
+package acl
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInheritance(t *testing.T) {
+	SetMessages(ioutil.Discard)
+	defer SetMessages(os.Stdout)
+
+	acls = newACLStore()
+	if err := acls.parseInputFile("../acl12.txt"); err != nil {
+		t.Fatal(err)
+	}
+	before := acls.clone()
+
+	// The file's own list overrides src/, then comes ./
+	acl, from, _ := acls.resolveFrom("src/lib/util.go")
+	want := "printList: (File: src/lib/util.go. , vegdahl (orw), crenshaw (orwx), root (orwx), " +
+		"deny mallory (w)) \n"
+	if acl.String() != want || from[0] != "src/lib/util.go" || from[1] != "src/" || from[2] != "./" ||
+		from[3] != "src/" {
+		t.Errorf("Fail: %v %v\n", acl, from)
+	}
+
+	// src/vendor blocks everything above it, but not the pattern
+	if d := acls.check("src/vendor/zlib/inflate.c", "crenshaw", R_READ); d.allowed {
+		t.Errorf("Fail: %v\n", d)
+	}
+	if d := acls.check("src/vendor/zlib/inflate.c", "ec2-user", R_EXEC); !d.allowed {
+		t.Errorf("Fail: %v\n", d)
+	}
+	if _, ok := acls.resolve("README"); !ok {
+		t.Errorf("Fail: README inherits nothing\n")
+	}
+
+	// ck goes by everything that applies too, and so does who may
+	// change a list: crenshaw owns src/, so what's under it as well
+	var out strings.Builder
+	SetMessages(&out)
+	cmds, _ := parseCommands(flatten(lex("test.txt",
+		"file src/lib/util.go\nck crenshaw o\nas crenshaw\nar vegdahl 1\nas mallory\ndr vegdahl 1")))
+	runner := newCommandRunner(acls)
+	for _, cmd := range cmds {
+		runner.run(cmd)
+	}
+	SetMessages(ioutil.Discard)
+	if !strings.Contains(out.String(), "Check allow: crenshaw wants o") {
+		t.Errorf("Fail: %s\n", out.String())
+	}
+	if len(runner.diags) != 1 || runner.diags[0].kind != D_DENIED ||
+		!acls.check("src/lib/util.go", "vegdahl", R_EXEC).allowed {
+		t.Errorf("Fail: %v\n", runner.diags)
+	}
+	runner.actAs("", false)
+	runner.undo(cmds[0], 1)
+
+	// Toggling inheritance, as an owner and not, and undoing it
+	cmds, _ = parseCommands(flatten(lex("test.txt",
+		"block src/vendor\nblock nope\nas vegdahl\nblock src/\nas crenshaw\nblock src/\nundo 1")))
+	runner = newCommandRunner(acls)
+	for _, cmd := range cmds {
+		runner.run(cmd)
+	}
+	if len(runner.diags) != 3 || runner.diags[0].kind != D_DUPLICATE ||
+		runner.diags[1].kind != D_UNKNOWN_FILE || runner.diags[2].kind != D_DENIED {
+		t.Errorf("Fail: %v\n", runner.diags)
+	}
+	if d := acls.check("src/main.c", "root", R_OWN); !d.allowed || len(runner.log) != 2 {
+		t.Errorf("Fail: %v %v\n", d, runner.log)
+	}
+
+	// Diffs and every format keep it
+	newCommandRunner(acls).run(command{verb: token{text: "inherit"}, args: []token{{text: "src/vendor"}}})
+	str, _ := diffStores(acls, before).commands()
+	if str != "block src/vendor\n" {
+		t.Errorf("Fail: %s\n", str)
+	}
+	for _, format := range []string{F_ACL, F_JSON, F_YAML} {
+		str, err := before.format(format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		filename := filepath.Join(t.TempDir(), "acl."+format)
+		ioutil.WriteFile(filename, []byte(str), 0644)
+
+		reread := newACLStore()
+		if err := reread.parseFileAs(filename, format); err != nil {
+			t.Fatal(err)
+		}
+		if !diffStores(before, reread).empty() {
+			t.Errorf("Fail: %s\n%v\n", format, reread)
+		}
+	}
+}
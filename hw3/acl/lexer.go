@@ -0,0 +1,97 @@
+package acl
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"unicode"
+)
+
+// Where something is in a file, for error messages.
+// Lines and columns count from 1.
+type position struct {
+	filename string
+	line     int
+	col      int
+}
+
+// Stringify a position the way compilers do, "file:line:col", or
+// just the file if there's no line to go with it
+func (p position) String() string {
+	if p.line == 0 {
+		return p.filename
+	}
+	return fmt.Sprintf("%s:%d:%d", p.filename, p.line, p.col)
+}
+
+// A token is a single word from a file along with where it was found
+type token struct {
+	pos  position
+	text string
+}
+
+// Split the text of a file into words, keeping track of the position
+// of each one. Words are separated by any amount of white space.
+// One slice of tokens is returned for each line, so parsers that care
+// about line breaks can see them and those that don't can flatten it.
+func lex(filename, text string) (lines [][]token) {
+	for lineIdx, line := range strings.Split(text, "\n") {
+		words := make([]token, 0)
+
+		// Columns count runes, not bytes. The extra space on the
+		// end finishes off the last word.
+		runes := []rune(line + " ")
+		start := -1
+		for idx, r := range runes {
+			switch {
+			case unicode.IsSpace(r) && start >= 0:
+				words = append(words, token{
+					position{filename, lineIdx + 1, start + 1},
+					string(runes[start:idx]),
+				})
+				start = -1
+			case !unicode.IsSpace(r) && start < 0:
+				start = idx
+			}
+		}
+
+		lines = append(lines, words)
+	}
+
+	return
+}
+
+// Read a whole file and lex it
+func lexFile(filename string) (lines [][]token, err error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	return lex(filename, string(data)), nil
+}
+
+// All the tokens on all the lines, one after the other
+func flatten(lines [][]token) (tokens []token) {
+	for _, line := range lines {
+		tokens = append(tokens, line...)
+	}
+	return
+}
+
+// Split a token that starts with a marker, like ':main.c' or '*root',
+// into the marker and the rest. rest is nil if there is nothing after
+// the marker. ok is false if the token doesn't start with marker.
+func splitMarker(t token, marker string) (rest *token, ok bool) {
+	if !strings.HasPrefix(t.text, marker) {
+		return nil, false
+	}
+
+	if t.text == marker {
+		return nil, true
+	}
+
+	pos := t.pos
+	pos.col += len([]rune(marker))
+	return &token{pos, strings.TrimPrefix(t.text, marker)}, true
+}
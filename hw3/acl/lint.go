@@ -0,0 +1,366 @@
+package acl
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+// Linting looks over an ACL file for things that parse fine but are
+// probably mistakes, like an entry with no rights or a file nobody
+// owns. Each rule has a severity, which a lint rules file can change
+// (see ReadFile), down to off.
+
+// How bad a lint finding is
+type Severity string
+
+const (
+	S_ERROR   Severity = "error"
+	S_WARNING Severity = "warning"
+	S_INFO    Severity = "info"
+	S_OFF     Severity = "off"
+)
+
+// Every rule, with what it looks for
+var lintRuleHelp = map[string]string{
+	"syntax":             "the file doesn't parse",
+	"duplicate-entry":    "a user has two entries of the same kind on a file; all but the first are ignored",
+	"no-owner":           "nobody owns a file",
+	"too-many-owners":    "a file has more owners than -max-owners allows",
+	"zero-rights":        "an entry grants (or denies) nothing",
+	"owner-without-read": "an owner can't read the file they own",
+	"unknown-group":      "an entry is for a group that isn't defined, or in the user directory",
+	"unknown-user":       "an entry is for a user the user directory doesn't have",
+	"expired-entry":      "an entry's time is up, and purge would remove it",
+	"blank-lines":        "blank lines in a row, or at the end of the file",
+	"trailing-space":     "a line ends with spaces or tabs",
+}
+
+// The severity of each rule
+type LintRules map[string]Severity
+
+// The rules as they are unless a lint rules file says otherwise
+func DefaultLintRules() LintRules {
+	return LintRules{
+		"syntax":             S_ERROR,
+		"duplicate-entry":    S_ERROR,
+		"no-owner":           S_ERROR,
+		"too-many-owners":    S_ERROR,
+		"zero-rights":        S_WARNING,
+		"owner-without-read": S_WARNING,
+		"unknown-group":      S_WARNING,
+		"unknown-user":       S_WARNING,
+		"expired-entry":      S_WARNING,
+		"blank-lines":        S_INFO,
+		"trailing-space":     S_INFO,
+	}
+}
+
+// Change the rules' severities from a file with a rule and a severity
+// on each line, e.g.
+//
+//	zero-rights error
+//	trailing-space off
+//
+// Rules the file doesn't mention are left as they are.
+func (rules LintRules) ReadFile(filename string) (err error) {
+	lines, err := lexFile(filename)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(messages(), "%s was successfully opened.\nParsing lint rules from file.\n", filename)
+
+	var diags diagnostics
+
+	for _, line := range lines {
+		if len(line) == 0 {
+			continue
+		}
+		if len(line) != 2 {
+			diags.add(line[0], D_SYNTAX, "expected a rule and its severity")
+			continue
+		}
+
+		if _, ok := lintRuleHelp[line[0].text]; !ok {
+			diags.add(line[0], D_SYNTAX, "not a lint rule")
+			continue
+		}
+		switch severity := Severity(line[1].text); severity {
+		case S_ERROR, S_WARNING, S_INFO, S_OFF:
+			rules[line[0].text] = severity
+		default:
+			diags.add(line[1], D_SYNTAX, "a severity is error, warning, info or off")
+		}
+	}
+
+	return diags.err()
+}
+
+// One thing lint found
+type LintFinding struct {
+	File     string   `json:"file"`
+	Line     int      `json:"line"`
+	Col      int      `json:"col"`
+	Severity Severity `json:"severity"`
+	Rule     string   `json:"rule"`
+	Message  string   `json:"message"`
+}
+
+// e.g. acl3.txt:5:3: warning: vegdahl has no rights [zero-rights]
+func (f LintFinding) String() string {
+	return fmt.Sprintf("%v: %s: %s [%s]", position{f.File, f.Line, f.Col}, f.Severity, f.Message, f.Rule)
+}
+
+// Findings, in the order they are in the file
+type LintFindings []LintFinding
+
+// How many findings have a severity
+func (fs LintFindings) Count(severity Severity) (n int) {
+	for _, f := range fs {
+		if f.Severity == severity {
+			n++
+		}
+	}
+	return
+}
+
+// One finding per line
+func (fs LintFindings) String() (str string) {
+	for _, f := range fs {
+		str += f.String() + "\n"
+	}
+	return
+}
+
+// The findings as a JSON array
+func (fs LintFindings) JSON() (str string, err error) {
+	if fs == nil {
+		fs = LintFindings{}
+	}
+	data, err := json.MarshalIndent(fs, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data) + "\n", nil
+}
+
+// Where an entry was in the file
+type lintEntry struct {
+	file *token
+	user token
+	deny bool
+}
+
+// Lint an ACL file. The file is read on its own, but with the store's
+// groups, owner limit and user directory, so '-g' groups, '-max-owners'
+// and '-passwd' count. err is only for a file that can't be read at
+// all; a file that doesn't parse is a 'syntax' finding.
+func (st *Store) Lint(filename string, rules LintRules) (findings LintFindings, err error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	st.mu.RLock()
+	s := st.s.clone()
+	st.mu.RUnlock()
+	s.lists = make(map[string]*accessControlList)
+	s.order = nil
+
+	// Unknown users are a finding of their own, not a syntax error
+	directory := s.directory
+	s.directory = nil
+
+	report := func(pos position, rule, format string, a ...interface{}) {
+		severity := rules[rule]
+		if severity == "" || severity == S_OFF {
+			return
+		}
+		findings = append(findings, LintFinding{pos.filename, pos.line, pos.col,
+			severity, rule, fmt.Sprintf(format, a...)})
+	}
+
+	if err := s.parseInputFile(filename); err != nil {
+		diags, ok := err.(diagnostics)
+		if !ok {
+			return nil, err
+		}
+		for _, d := range diags {
+			msg := d.msg
+			if d.token != "" {
+				msg += fmt.Sprintf(" (%q)", d.token)
+			}
+			report(d.pos, "syntax", "%s", msg)
+		}
+	}
+
+	s.directory = directory
+
+	lines := lex(filename, string(data))
+	lintLines(filename, string(data), lines, report)
+	files, entries := lintEntries(lines)
+
+	// Where each list and entry starts, for the rules about them
+	listPos := make(map[string]position)
+	for _, file := range files {
+		if _, ok := listPos[file.text]; !ok {
+			listPos[file.text] = file.pos
+		}
+	}
+	entryPos := make(map[string]position)
+	seen := make(map[string]bool)
+	for _, entry := range entries {
+		key := fmt.Sprintf("%s\x00%s\x00%v", entry.file.text, entry.user.text, entry.deny)
+		if seen[key] {
+			report(entry.user.pos, "duplicate-entry", "%s already has an entry on %s, this one is ignored",
+				entry.user.text, entry.file.text)
+			continue
+		}
+		seen[key] = true
+		entryPos[key] = entry.user.pos
+	}
+
+	for _, name := range s.order {
+		acl := s.lists[name]
+		pos, ok := listPos[name]
+		if !ok {
+			pos = position{filename, 1, 1}
+		}
+
+		owners := 0
+		for _, entry := range acl.ace.all() {
+			at := entryPos[fmt.Sprintf("%s\x00%s\x00%v", name, entry.user, entry.deny)]
+			if at.filename == "" {
+				at = pos
+			}
+
+			if entry.isOwner() {
+				owners++
+			}
+			if entry.effective() == 0 {
+				report(at, "zero-rights", "%s has no rights on %s", entry.user, name)
+			}
+			if entry.isOwner() && entry.effective()&R_READ == 0 {
+				report(at, "owner-without-read", "%s owns %s but can't read it", entry.user, name)
+			}
+			if group, ok := entry.group(); ok {
+				_, defined := s.groups.members[group]
+				if !defined && (s.directory == nil || !s.directory.groups[group]) {
+					report(at, "unknown-group", "group %s isn't defined", group)
+				}
+			} else if problem := s.unknownName(entry.user); problem != "" {
+				report(at, "unknown-user", "%s", problem)
+			}
+			if entry.expiredAt(now()) {
+				report(at, "expired-entry", "%s's entry on %s expired %s", entry.user, name,
+					formatTimeLimit(entry.expires))
+			}
+		}
+
+		if owners == 0 {
+			report(pos, "no-owner", "nobody owns %s", name)
+		}
+		if s.maxOwners > 0 && owners > s.maxOwners {
+			report(pos, "too-many-owners", "%s has %d owners, more than %d", name, owners, s.maxOwners)
+		}
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].Line != findings[j].Line {
+			return findings[i].Line < findings[j].Line
+		}
+		return findings[i].Col < findings[j].Col
+	})
+	return
+}
+
+// The rules about the text of the file itself, rather than what it says
+func lintLines(filename, text string, lines [][]token, report func(pos position, rule, format string, a ...interface{})) {
+	raw := strings.Split(text, "\n")
+
+	// A file ending in a newline has an empty last line that isn't
+	// really a line
+	last := len(lines)
+	if strings.HasSuffix(text, "\n") {
+		last--
+	}
+
+	// Runs of blank lines are reported once, where they start
+	blanks := 0
+	for idx := 0; idx <= last; idx++ {
+		if idx < last && len(lines[idx]) == 0 {
+			blanks++
+			continue
+		}
+
+		start := position{filename, idx - blanks + 1, 1}
+		switch {
+		case blanks > 0 && idx == last && idx > blanks:
+			report(start, "blank-lines", "blank lines at the end of the file")
+		case blanks > 1 && idx < last:
+			report(start, "blank-lines", "%d blank lines in a row", blanks)
+		}
+		blanks = 0
+
+		if idx == last {
+			break
+		}
+		line := strings.TrimSuffix(raw[idx], "\r")
+		if trimmed := strings.TrimRight(line, " \t"); trimmed != line {
+			report(position{filename, idx + 1, len([]rune(trimmed)) + 1}, "trailing-space",
+				"line ends with white space")
+		}
+	}
+}
+
+// Every file named in an ACL file, and every entry with the file it's
+// for, as written. The parser only keeps the first of two entries for
+// a user, so this is the only way to see the second.
+func lintEntries(lines [][]token) (files []token, entries []lintEntry) {
+	var file *token
+
+	for _, line := range lines {
+		if len(line) == 0 {
+			continue
+		}
+		if _, ok := splitMarker(line[0], "g:"); ok {
+			continue
+		}
+		if rest, ok := splitMarker(line[0], ":"); ok {
+			if file, _ = markedWord(line, rest); file != nil {
+				files = append(files, *file)
+			}
+			continue
+		}
+
+		marker := "*"
+		if strings.HasPrefix(line[0].text, "-") {
+			marker = "-"
+		}
+		rest, ok := splitMarker(line[0], marker)
+		if !ok || file == nil {
+			continue
+		}
+		if user, _ := markedWord(line, rest); user != nil {
+			entries = append(entries, lintEntry{file, *user, marker == "-"})
+		}
+	}
+
+	return
+}
+
+// The names of every lint rule, sorted, with what each looks for
+func LintRuleHelp() (str string) {
+	var names []string
+	for name := range lintRuleHelp {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		str += fmt.Sprintf("  %-18s %s\n", name, lintRuleHelp[name])
+	}
+	return
+}
@@ -0,0 +1,75 @@
+package acl
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	SetMessages(ioutil.Discard)
+	defer SetMessages(os.Stdout)
+
+	store := NewStore()
+	rules := DefaultLintRules()
+
+	lint := func(filename string) (got []string) {
+		findings, err := store.Lint(filename, rules)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, f := range findings {
+			got = append(got, fmt.Sprintf("%d %s %s", f.Line, f.Severity, f.Rule))
+		}
+		return
+	}
+
+	tests := []struct {
+		filename string
+		want     string
+	}{
+		{"../acl1.txt", "10 info blank-lines"},
+		{"../acl3.txt", "4 warning zero-rights,10 info blank-lines"},
+		{"../acl6.txt", "1 error no-owner,2 info blank-lines"},
+		{"../acl7.txt", "10 error duplicate-entry,12 info blank-lines"},
+	}
+	for _, test := range tests {
+		if got := strings.Join(lint(test.filename), ","); got != test.want {
+			t.Errorf("Fail: %s: %s, not %s\n", test.filename, got, test.want)
+		}
+	}
+
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "acl.txt")
+	ioutil.WriteFile(filename, []byte(": a.c \n* bob ow\n\n\n* @ops r\n* bob x\n: b.c\n* carol 7\n"), 0644)
+	want := "1 info trailing-space,2 warning owner-without-read,3 info blank-lines," +
+		"5 warning unknown-group,6 error duplicate-entry,7 error no-owner"
+	if got := strings.Join(lint(filename), ","); got != want {
+		t.Errorf("Fail: %s, not %s\n", got, want)
+	}
+
+	// Severities can be changed, or rules turned off
+	rulesFile := filepath.Join(dir, "rules.txt")
+	ioutil.WriteFile(rulesFile, []byte("trailing-space off\nblank-lines off\nowner-without-read error\n"), 0644)
+	if err := rules.ReadFile(rulesFile); err != nil {
+		t.Fatal(err)
+	}
+	want = "2 error owner-without-read,5 warning unknown-group,6 error duplicate-entry,7 error no-owner"
+	if got := strings.Join(lint(filename), ","); got != want {
+		t.Errorf("Fail: %s, not %s\n", got, want)
+	}
+
+	ioutil.WriteFile(rulesFile, []byte("zero-rights loud\nno-such-rule off\n"), 0644)
+	if err := rules.ReadFile(rulesFile); err == nil || len(err.(diagnostics)) != 2 {
+		t.Errorf("Fail: %v\n", err)
+	}
+
+	// Findings that are for a file that doesn't parse
+	ioutil.WriteFile(filename, []byte(": a.c\n* alice 15\n* bob 99\n"), 0644)
+	if got := strings.Join(lint(filename), ","); got != "3 error syntax" {
+		t.Errorf("Fail: %s\n", got)
+	}
+}
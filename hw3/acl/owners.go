@@ -0,0 +1,56 @@
+package acl
+
+import (
+	"fmt"
+)
+
+// Every list is expected to keep at least one owner, and at least one
+// whose entry doesn't expire, so a list never ends up with nobody
+// owning it just because time has passed (see expiry.go). A store can
+// also put a limit on how many owners a list may have. Changes made
+// by commands that break either rule are rejected. Lists that already
+// break a rule when loaded can still be changed, as long as the change
+// doesn't make things worse.
+
+// Number of owners a list has, and how many of them don't expire
+func (acl *accessControlList) owners() (n, lasting int) {
+	return acl.ace.ownerCount(), acl.ace.lastingCount()
+}
+
+// Why a change that left a list with its current owners, where it had
+// before owners (lasting of them for good), breaks the ownership rules.
+// Empty if it doesn't.
+func (s *aclStore) ownerProblem(acl *accessControlList, before, beforeLasting int) string {
+	after, lasting := acl.owners()
+
+	switch {
+	case after == 0 && before > 0:
+		return fmt.Sprintf("%s has to keep at least one owner", acl.filename)
+	case lasting == 0 && beforeLasting > 0:
+		return fmt.Sprintf("%s has to keep at least one owner whose entry doesn't expire", acl.filename)
+	case s.maxOwners > 0 && after > s.maxOwners && after > before:
+		return fmt.Sprintf("%s can't have more than %d owners", acl.filename, s.maxOwners)
+	}
+
+	return ""
+}
+
+// Set the user commands are run as. If fixed, the command file isn't
+// allowed to switch to anybody else with 'as'.
+func (cr *commandRunner) actAs(principal string, fixed bool) {
+	cr.principal = principal
+	cr.fixedPrincipal = fixed && principal != ""
+}
+
+// May the user commands are run as change the list for a file? Only
+// owners may, unless nobody in particular is acting, which is how
+// command files without an 'as' have always worked. Owning is what
+// check says it is, so ownership that comes from a pattern or a
+// directory above the file counts (see inherit.go).
+func (cr *commandRunner) mayChange(filename string) bool {
+	if cr.principal == "" {
+		return true
+	}
+
+	return cr.store.check(filename, cr.principal, R_OWN).allowed
+}
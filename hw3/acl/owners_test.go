@@ -0,0 +1,47 @@
+package acl
+
+import (
+	"testing"
+)
+
+func TestOwnership(t *testing.T) {
+	acls = newACLStore()
+	acls.maxOwners = 2
+
+	if err := acls.parseInputFile("../acl1.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	run := func(str string) diagnostics {
+		cmds, diags := parseCommands(flatten(lex("test.txt", str)))
+		if len(diags) > 0 {
+			t.Fatal(diags)
+		}
+		runner := newCommandRunner(acls)
+		for _, cmd := range cmds {
+			runner.run(cmd)
+		}
+		return runner.diags
+	}
+
+	// The last owner can't go, and was put back
+	if diags := run("dr crenshaw 8"); len(diags) != 1 || diags[0].kind != D_INVARIANT {
+		t.Errorf("Fail: %v\n", diags)
+	}
+	if d := acls.check("main.c", "crenshaw", R_OWN); !d.allowed {
+		t.Errorf("Fail: %v\n", d)
+	}
+
+	// Nor can there be too many
+	if diags := run("ar vegdahl 8 ar ubuntu 8"); len(diags) != 1 || diags[0].token != "ubuntu" {
+		t.Errorf("Fail: %v\n", diags)
+	}
+
+	// Only owners may change the list
+	if diags := run("as ubuntu ar ubuntu 1 ck ubuntu 1"); len(diags) != 1 || diags[0].kind != D_DENIED {
+		t.Errorf("Fail: %v\n", diags)
+	}
+	if diags := run("as vegdahl ar ubuntu 1 dr crenshaw 8"); len(diags) != 0 {
+		t.Errorf("Fail: %v\n", diags)
+	}
+}
@@ -0,0 +1,462 @@
+package acl
+
+import (
+	"fmt"
+	"strings"
+)
+
+/* parseInputFile
+ *
+ * Description: This function reads an input file and constructs an
+ *              access control list from the contents of the file.  The
+ *              expected input file format is:
+ *
+ *     : <filename>
+ *     * user1
+ *     <integer describing rights for user1>
+ *     * user2
+ *     <integer describing rights for user2>
+ *     ...
+ *     * userN
+ *     <integer describing rights for userN>
+ *
+ *  The set of rights available are own, read, write, and execute and
+ *  are expressed in four bits.  For example, if a user owns and may read
+ *  the file, his set of rights is expressed by 0b1100 or 12. The rights
+ *  may also go on the same line as the user, and may be written as
+ *  letters ('or') or names ('own,read') instead. More rights can be
+ *  defined with a -rights file, see rights.go.
+ *
+ *  Every ': <filename>' line starts the list for a new file, and one
+ *  access control list is added to the store for each of them. A file
+ *  named twice keeps adding to the same list. The filename may be a
+ *  pattern such as '*.c' or 'src/*.go', see glob.go. A directory's
+ *  list is inherited by everything under it, unless 'noinherit'
+ *  follows the filename of a list below it, see inherit.go.
+ *
+ *  Groups are defined on a line of their own, anywhere in the file:
+ *
+ *     g: <group> member1 member2 ... memberN
+ *
+ *  and an entry for a group names it with an '@', e.g. '* @ops'.
+ *
+ *  A deny entry is written like any other, but starts with a '-'
+ *  instead of a '*':
+ *
+ *     - mallory
+ *     <integer describing rights denied to mallory>
+ *
+ *  Any entry may be limited in time by following its rights with
+ *  'from <time>' and/or 'until <time>', e.g. '* carol 6 until 2015-12-31'.
+ *  It doesn't count before its 'from' time, or from its 'until' on.
+ *
+ *  Roles (see roles.go) follow the rights too, each with a '%', e.g.
+ *  '* vegdahl r %reviewer'. Deny entries can't hold roles. An entry
+ *  with nothing but roles may leave the rights out, e.g.
+ *  '* vegdahl %maintainer'.
+ *
+ *  Usernames may not begin with a colon, an asterix, a dash or an
+ *  at sign.
+ *
+ *  Problems are returned as diagnostics, with the position of each.
+ *  Parsing carries on past them, so all of them are found in one go.
+ *
+ */
+func (s *aclStore) parseInputFile(filename string) (err error) {
+
+	// attempt to read the file
+	lines, err := lexFile(filename)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(s.messages(), "%s was successfully opened.\nParsing access control entries from file.\n", filename)
+
+	var diags diagnostics
+
+	// The list entries are currently being added to
+	var acl *accessControlList
+
+	// Who the entries are for, and the groups' members, to check
+	// against the user directory once every group in the file is
+	// defined
+	var names []token
+
+	for lineIdx := 0; lineIdx < len(lines); lineIdx++ {
+		line := lines[lineIdx]
+
+		// Blank lines are fine
+		if len(line) == 0 {
+			continue
+		}
+
+		// Group definitions have to be checked for before file
+		// names, since they start with a 'g:' rather than a ':'
+		if rest, ok := splitMarker(line[0], "g:"); ok {
+			words := line[1:]
+			if rest != nil {
+				words = append([]token{*rest}, words...)
+			}
+			names = append(names, parseGroupLine(line[0], words, s.groups, &diags)...)
+			continue
+		}
+
+		// If a colon is read, the next word is the file for the ACL
+		if rest, ok := splitMarker(line[0], ":"); ok {
+			name, extra := markedWord(line, rest)
+			block := len(extra) > 0 && extra[0].text == noInherit
+			if block {
+				extra = extra[1:]
+			}
+			switch {
+			case name == nil:
+				diags.missing(line[0].pos, "missing file name after ':'")
+				continue
+			case len(extra) > 0:
+				diags.add(extra[0], D_SYNTAX, "unexpected word after file name")
+			}
+
+			// Start (or continue) the list for that file
+			acl, _ = s.add(name.text)
+			if block {
+				acl.noInherit = true
+			}
+			continue
+		}
+
+		// if there's a '*' or '-' then next word is username
+		marker := "*"
+		if strings.HasPrefix(line[0].text, "-") {
+			marker = "-"
+		}
+		rest, ok := splitMarker(line[0], marker)
+		if !ok {
+			diags.add(line[0], D_SYNTAX, "expected ':', '*', '-' or 'g:'")
+			continue
+		}
+
+		user, extra := markedWord(line, rest)
+		if user == nil {
+			diags.missing(line[0].pos, "missing user name after '%s'", marker)
+			continue
+		}
+
+		// The rights are either the next word on the same line, or
+		// the first word on the next line. Either way, time limits
+		// may follow them.
+		var rightsTok token
+		var limits []token
+		switch {
+		case len(extra) > 0:
+			rightsTok, limits = extra[0], extra[1:]
+		case lineIdx+1 < len(lines) && len(lines[lineIdx+1]) > 0 &&
+			!isMarked(lines[lineIdx+1][0]):
+			lineIdx++
+			rightsTok, limits = lines[lineIdx][0], lines[lineIdx][1:]
+		default:
+			diags.missing(user.pos, "missing rights for user %s", user.text)
+			continue
+		}
+
+		// Just roles, no rights of its own
+		var d right
+		if strings.HasPrefix(rightsTok.text, rolePrefix) {
+			limits = append([]token{rightsTok}, limits...)
+		} else if d, err = parseRight(rightsTok); err != nil {
+			diags.add(rightsTok, D_BAD_RIGHT, "%v", err)
+			continue
+		}
+
+		entry := accessControlEntry{user: user.text, rights: d, deny: marker == "-"}
+		if !parseEntryExtras(&entry, limits, &diags) {
+			continue
+		}
+
+		// fmt.Printf("user: %s\nrights: %d\n", user.text, d)
+
+		// Entries have to belong to some file
+		if acl == nil {
+			diags.add(*user, D_SYNTAX, "entry comes before any ': <filename>' line")
+			continue
+		}
+		names = append(names, *user)
+
+		if entry.deny {
+			ok = acl.addDenyEntry(user.text, d)
+		} else {
+			ok = acl.addEntry(user.text, d)
+		}
+		if ok {
+			idx := acl.indexOf(user.text, entry.deny)
+			added := acl.ace.at(idx)
+			added.notBefore, added.expires = entry.notBefore, entry.expires
+			added.roles = entry.roles
+			acl.ace.set(idx, added)
+
+			// Roles may make an owner of an entry that didn't
+			// look like one
+			if entry.isOwner() && entry.rights&R_OWN == 0 {
+				acl.reorder(idx)
+			}
+		}
+	}
+
+	for _, name := range names {
+		s.checkKnownName(name, &diags)
+	}
+
+	return diags.err()
+}
+
+// Parse what may follow an entry's rights into the entry: time limits,
+// which are pairs like 'from 2015-09-01' and 'until 2015-12-31T17:00:00Z',
+// and roles like '%reviewer'. ok is false if there was a problem with
+// them.
+func parseEntryExtras(entry *accessControlEntry, words []token, diags *diagnostics) (ok bool) {
+	ok = true
+
+	for idx := 0; idx < len(words); idx += 2 {
+		word := words[idx]
+
+		if strings.HasPrefix(word.text, rolePrefix) {
+			role := strings.TrimPrefix(word.text, rolePrefix)
+			switch {
+			case entry.deny:
+				diags.add(word, D_SYNTAX, "deny entries can't hold roles")
+				ok = false
+			case !roles.defined(role):
+				diags.add(word, D_UNKNOWN_ROLE, "no such role")
+				ok = false
+			case entry.hasRole(role):
+				diags.add(word, D_DUPLICATE, "role given twice")
+				ok = false
+			default:
+				entry.roles = append(entry.roles, role)
+			}
+
+			// Roles are one word, not two
+			idx--
+			continue
+		}
+
+		if word.text != "from" && word.text != "until" {
+			diags.add(word, D_SYNTAX, "unexpected word after rights")
+			return false
+		}
+		if idx+1 >= len(words) {
+			diags.missing(word.pos, "missing time after '%s'", word.text)
+			return false
+		}
+
+		t, err := parseTimeLimit(words[idx+1].text)
+		if err != nil {
+			diags.add(words[idx+1], D_SYNTAX, "%v", err)
+			ok = false
+			continue
+		}
+
+		if word.text == "from" {
+			entry.notBefore = t
+		} else {
+			entry.expires = t
+		}
+	}
+
+	return
+}
+
+// Does a token start one of the marked lines of an ACL file?
+func isMarked(t token) bool {
+	for _, marker := range []string{"g:", ":", "*", "-"} {
+		if strings.HasPrefix(t.text, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// The word that goes with a marker at the start of a line, either
+// what was stuck to the marker or the next word on the line, along
+// with whatever else is on the line after it.
+func markedWord(line []token, rest *token) (word *token, extra []token) {
+	if rest != nil {
+		return rest, line[1:]
+	}
+	if len(line) > 1 {
+		return &line[1], line[2:]
+	}
+	return nil, nil
+}
+
+// Parse the words of a group definition after the 'g:', that is the
+// group name followed by its members, into groups. The members are
+// returned, to be checked against the user directory.
+func parseGroupLine(start token, words []token, groups *groupTable, diags *diagnostics) (members []token) {
+	if len(words) == 0 {
+		diags.missing(start.pos, "group definition without a group name")
+		return nil
+	}
+
+	groups.addGroup(words[0].text)
+	for _, member := range words[1:] {
+		groups.addMember(words[0].text, member.text)
+	}
+	return words[1:]
+}
+
+// Reads a file of nothing but group definitions, one per line,
+// in the same 'g: <group> member1 ... memberN' form an ACL file
+// uses. Blank lines are skipped.
+func (s *aclStore) parseGroupFile(filename string) (err error) {
+
+	// attempt to read the file
+	lines, err := lexFile(filename)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(s.messages(), "%s was successfully opened.\nParsing groups from file.\n", filename)
+
+	var diags diagnostics
+	var names []token
+
+	for _, line := range lines {
+		if len(line) == 0 {
+			continue
+		}
+
+		rest, ok := splitMarker(line[0], "g:")
+		if !ok {
+			diags.add(line[0], D_SYNTAX, "expected a 'g:' group definition")
+			continue
+		}
+
+		words := line[1:]
+		if rest != nil {
+			words = append([]token{*rest}, words...)
+		}
+		names = append(names, parseGroupLine(line[0], words, s.groups, &diags)...)
+	}
+
+	// Members are checked once every group is defined, since they may
+	// be groups too
+	for _, name := range names {
+		s.checkKnownName(name, &diags)
+	}
+
+	return diags.err()
+}
+
+/* Function: parseCommandFile()
+ * Parameters: 1. filename: The name of the file to be parsed.
+ *
+ * Description: This function reads an input file and alters the access
+ *              control lists in the store based on the contents of the
+ *              file.  The possible commands are:
+ *
+ *    dr: Delete Right.
+ *    ar: Add Right.
+ *    ae: Add Entry, a new user with a set of rights, e.g. 'ae alice 6'.
+ *    de: Delete Entry.
+ *    ad: Add Deny, taking a right away from a user whatever else grants it.
+ *    rd: Remove Deny.
+ *    ck: Check whether a user holds a set of rights, printing the decision.
+ *    ga: Group Add, e.g. 'ga ops alice' makes alice a member of ops.
+ *    gr: Group Remove, e.g. 'gr ops alice' takes her out again.
+ *    file: Apply the commands that follow to the named file's list.
+ *    as: Run the commands that follow as a user, who has to own a list
+ *        to change it. Without one, commands run as an administrator.
+ *    at: Add Temporary rights, which last until a time or for a while,
+ *        e.g. 'at alice 6 2015-12-31' or 'at alice 6 30d'. A user with
+ *        an entry that doesn't expire can't be given them, it would
+ *        expire along with them; one that does expire gets moved.
+ *    rules: Print which lists apply to a path, and what they add up to,
+ *        e.g. 'rules src/main.go'.
+ *    purge: Remove every expired entry from the lists, printing each.
+ *    undo: Undo the last N changes, e.g. 'undo 2'. A purge is one change.
+ *        Only changes made earlier in the same file (or shell session)
+ *        can be undone, not those of earlier runs.
+ *    redo: Redo the last N changes undone.
+ *    assign: Give a user a role, e.g. 'assign vegdahl maintainer',
+ *        making them an entry if they have none. See roles.go.
+ *    revoke: Take a role away from a user, e.g. 'revoke vegdahl maintainer'.
+ *    block: Stop a file's list inheriting from the directories above
+ *        it, e.g. 'block vendor/'. See inherit.go.
+ *    inherit: Let a file's list inherit again, e.g. 'inherit vendor/'.
+ *    effective: Print the list that applies to a path and where each
+ *        entry in it came from, e.g. 'effective src/lib/util.go'.
+ *
+ *  For example, if the file reads,
+ *
+ *  file main.c
+ *  dr
+ *  vegdahl
+ *  4
+ *
+ *  Then the access control list for main.c should be altered so that
+ *  the user 'vegdahl' no longer has the right to 'read' the file.  See
+ *  aclist.go for a mapping from integers to rights. Rights may be given
+ *  as letters or names too, so 'dr vegdahl r' and 'dr vegdahl read'
+ *  do the same.
+ *
+ *  Until a 'file' directive is seen, commands apply to the first list
+ *  in the input file. Words may be split over lines or share a line.
+ *
+ *  Whoever runs them, commands may not leave a list without an owner,
+ *  or with more than the -max-owners limit of them.
+ *
+ *  The commands are applied as a single transaction: if the file
+ *  doesn't parse, or any command in it fails, none of them take effect.
+ *  Neither do they if the audit log can't be written.
+ *  Problems with parsing or running the commands, like unknown
+ *  commands, bad rights or users without entries, are returned
+ *  together as diagnostics.
+ *
+ */
+func (s *aclStore) parseCommandFile(filename, principal, auditLog string) (err error) {
+	runner, err := s.runCommandFile(filename, principal)
+	if err != nil {
+		return err
+	}
+
+	// Every command worked, so log what changed, and only commit once
+	// that's done, so nothing is changed that isn't in the log
+	if auditLog != "" {
+		if err := writeAuditLog(auditLog, runner.log); err != nil {
+			return err
+		}
+	}
+
+	*s = *runner.store
+	return nil
+}
+
+// Run the commands in a file as principal (see owners.go) against a
+// copy of the store, leaving the store itself alone, and return the
+// runner, which holds the altered copy and the changes made to it. If
+// the file doesn't parse nothing is run at all, and if any command
+// fails the copy is thrown away and the problems are returned instead.
+func (s *aclStore) runCommandFile(filename, principal string) (runner *commandRunner, err error) {
+
+	// attempt to read the file
+	lines, err := lexFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(s.messages(), "%s was successfully opened.\nParsing commands from file.\n", filename)
+
+	cmds, diags := parseCommands(flatten(lines))
+	if len(diags) > 0 {
+		return nil, diags
+	}
+
+	runner = newCommandRunner(s.clone())
+	runner.actAs(principal, true)
+	for _, cmd := range cmds {
+		runner.run(cmd)
+	}
+
+	if len(runner.diags) > 0 {
+		return nil, runner.diags
+	}
+
+	return runner, nil
+}
@@ -0,0 +1,46 @@
+package acl
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestTransaction(t *testing.T) {
+	acls = newACLStore()
+
+	if err := acls.parseInputFile("../acl4.txt"); err != nil {
+		t.Fatal(err)
+	}
+	before := acls.String()
+
+	// The last command fails, so the first ones mustn't stick
+	filename := filepath.Join(t.TempDir(), "commands.txt")
+	if err := writeFileAtomic(filename, []byte("de vegdahl\nar crenshaw 1\nar nobody 1\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := acls.parseCommandFile(filename, "", ""); err == nil {
+		t.Errorf("Fail: no error\n")
+	}
+	if after := acls.String(); after != before {
+		t.Errorf("Fail: %s\n", after)
+	}
+
+	// Nor do changes that can't be logged
+	if err := acls.parseCommandFile("../commands5.txt", "", t.TempDir()); err == nil {
+		t.Errorf("Fail: logged to a directory\n")
+	}
+	if after := acls.String(); after != before {
+		t.Errorf("Fail: %s\n", after)
+	}
+
+	// A dry run reports the changes without making them
+	runner, err := acls.runCommandFile("../commands5.txt", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes := diffStores(acls, runner.store)
+	if len(changes.entries) != 3 || acls.String() != before {
+		t.Errorf("Fail: %v\n", changes.entries)
+	}
+}
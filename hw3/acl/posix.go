@@ -0,0 +1,207 @@
+package acl
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Lists can be applied to a real directory tree as mode bits, and
+// read back from one. Each file's list is the one resolve() gives its
+// path relative to the root, so patterns work as they do anywhere
+// else. Mode bits only have room for three entries:
+//
+//	owner: the entry for the user the file belongs to
+//	group: the entry for the file's group, e.g. '@staff'
+//	other: the entry for the user named 'other'
+//
+// and only for reading, writing and executing. Everything else a list
+// says is reported as something mode bits can't express.
+
+// Input format for reading lists from a directory tree
+const F_DIR = "dir"
+
+// The user whose entry stands for everyone else
+const posixOther = "other"
+
+// The rights mode bits have room for. Their bits happen to be the
+// same as a mode's 'rwx' bits, so no converting is needed.
+const posixRights = R_READ | R_WRITE | R_EXEC
+
+// A file whose mode has to change
+type modeChange struct {
+	path     string
+	from, to os.FileMode
+}
+
+func (c modeChange) String() string {
+	return fmt.Sprintf("chmod %04o %s (was %04o)", c.to.Perm(), c.path, c.from.Perm())
+}
+
+// Something a list says about a file that its mode can't
+type modeProblem struct {
+	path   string
+	entry  accessControlEntry
+	reason string
+}
+
+func (p modeProblem) String() string {
+	user := p.entry.user
+	if p.entry.deny {
+		user = "deny " + user
+	}
+	return fmt.Sprintf("%s: %s (%s%s%s) %s", p.path, user, p.entry.effective(), p.entry.roleNames(),
+		p.entry.timeLimits(), p.reason)
+}
+
+// What applying a store to a directory tree would do
+type ModePlan struct {
+	changes  []modeChange
+	problems []modeProblem
+	// Files a list applies to that already have the right mode
+	unchanged int
+}
+
+// The rights a list leaves a user with, after denies
+func (acl *accessControlList) effective(username string) right {
+	d := acl.check(username, 0)
+	return d.granted &^ d.denied
+}
+
+// The permission bits a list comes to for a file belonging to owner
+// and group, along with everything in the list they leave out
+func (acl *accessControlList) toMode(owner, group string) (perm os.FileMode, problems []modeProblem) {
+	perm = os.FileMode(acl.effective(owner)&posixRights)<<6 |
+		os.FileMode(acl.effective(groupPrefix+group)&posixRights)<<3 |
+		os.FileMode(acl.effective(posixOther)&posixRights)
+
+	t := now()
+	for _, entry := range acl.ace.all() {
+		problem := modeProblem{path: acl.filename, entry: entry}
+
+		switch {
+		case entry.user != owner && entry.user != groupPrefix+group && entry.user != posixOther:
+			problem.reason = fmt.Sprintf("isn't the owner (%s), group (%s%s) or %s",
+				owner, groupPrefix, group, posixOther)
+		case entry.isOwner() && entry.user != owner:
+			problem.reason = fmt.Sprintf("can't own a file that belongs to %s", owner)
+		case entry.effective()&^(posixRights|R_OWN) != 0:
+			problem.reason = fmt.Sprintf("has rights (%s) without mode bits",
+				entry.effective()&^(posixRights|R_OWN))
+		case !entry.notBefore.IsZero() || !entry.expires.IsZero():
+			if entry.activeAt(t) {
+				problem.reason = "won't expire from the mode bits"
+			} else {
+				problem.reason = "isn't in the mode bits, since it isn't active now"
+			}
+		default:
+			continue
+		}
+
+		problems = append(problems, problem)
+	}
+
+	return
+}
+
+// Work out the mode of every file under root that a list in the store
+// applies to. Files no list applies to are left alone.
+func (s *aclStore) planModes(root string) (plan ModePlan, err error) {
+	err = filepath.Walk(root, func(filename string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, filename)
+		if err != nil {
+			return err
+		}
+		acl, ok := s.resolve(filepath.ToSlash(rel))
+		if !ok {
+			return nil
+		}
+
+		owner, group := fileOwners(info)
+		perm, problems := acl.toMode(owner, group)
+		plan.problems = append(plan.problems, problems...)
+
+		if perm == info.Mode().Perm() {
+			plan.unchanged++
+			return nil
+		}
+		plan.changes = append(plan.changes, modeChange{filename, info.Mode(), info.Mode()&^os.ModePerm | perm})
+		return nil
+	})
+
+	return
+}
+
+// Print what a plan does and what it can't
+func (plan ModePlan) Print() {
+	for _, change := range plan.changes {
+		fmt.Printf("%v\n", change)
+	}
+	fmt.Printf("%d files to change, %d already right.\n", len(plan.changes), plan.unchanged)
+
+	if len(plan.problems) > 0 {
+		fmt.Printf("Entries mode bits can't express:\n")
+		for _, problem := range plan.problems {
+			fmt.Printf("  %v\n", problem)
+		}
+	}
+}
+
+// Make the changes in a plan
+func (plan ModePlan) Apply() (err error) {
+	for _, change := range plan.changes {
+		if err := os.Chmod(change.path, change.to); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Read the lists for every file under a directory from their mode
+// bits and who they belong to. The owner gets an entry that owns the
+// file, and the group and other get entries if they have any rights.
+func (s *aclStore) parseDir(root string) (err error) {
+	if _, err := os.Stat(root); err != nil {
+		return err
+	}
+	fmt.Fprintf(s.messages(), "%s was successfully opened.\nReading permissions from directory.\n", root)
+
+	return filepath.Walk(root, func(filename string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, filename)
+		if err != nil {
+			return err
+		}
+		acl, _ := s.add(filepath.ToSlash(rel))
+		acl.fromMode(info)
+
+		return nil
+	})
+}
+
+// Fill a list in from a file's mode and ownership
+func (acl *accessControlList) fromMode(info os.FileInfo) {
+	owner, group := fileOwners(info)
+	perm := info.Mode().Perm()
+
+	acl.addEntry(owner, R_OWN|right(perm>>6)&posixRights)
+	if bits := right(perm>>3) & posixRights; bits != 0 {
+		acl.addEntry(groupPrefix+group, bits)
+	}
+	if bits := right(perm) & posixRights; bits != 0 {
+		acl.addEntry(posixOther, bits)
+	}
+}
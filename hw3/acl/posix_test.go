@@ -0,0 +1,49 @@
+package acl
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPOSIX(t *testing.T) {
+	acls = newACLStore()
+
+	root := t.TempDir()
+	filename := filepath.Join(root, "main.c")
+	if err := ioutil.WriteFile(filename, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	info, _ := os.Stat(filename)
+	owner, group := fileOwners(info)
+
+	acl, _ := acls.add("*.c")
+	acl.addEntry(owner, R_OWN|R_READ|R_WRITE)
+	acl.addEntry(groupPrefix+group, R_READ)
+	acl.addEntry(posixOther, R_READ)
+	acl.addEntry("vegdahl", R_READ)
+
+	plan, err := acls.planModes(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.changes) != 1 || plan.changes[0].to.Perm() != 0644 || len(plan.problems) != 1 ||
+		plan.problems[0].entry.user != "vegdahl" {
+		t.Fatalf("Fail: %v %v\n", plan.changes, plan.problems)
+	}
+	if err := plan.Apply(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Reading the tree back gives the list without what couldn't be applied
+	acl.deleteEntry("vegdahl")
+	want, _ := acls.resolve("main.c")
+	acls = newACLStore()
+	if err := acls.parseDir(root); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := acls.lookup("main.c"); got.String() != want.String() {
+		t.Errorf("Fail: %v\n%v\n", want, got)
+	}
+}
@@ -0,0 +1,31 @@
+//go:build unix
+
+package acl
+
+import (
+	"os"
+	osuser "os/user"
+	"strconv"
+	"syscall"
+)
+
+// The names of the user and group a file belongs to. Names that can't
+// be looked up are left as numbers. Empty on systems without them,
+// see posix_other.go.
+func fileOwners(info os.FileInfo) (owner, group string) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return "", ""
+	}
+
+	owner = strconv.FormatUint(uint64(st.Uid), 10)
+	if u, err := osuser.LookupId(owner); err == nil {
+		owner = u.Username
+	}
+	group = strconv.FormatUint(uint64(st.Gid), 10)
+	if g, err := osuser.LookupGroupId(group); err == nil {
+		group = g.Name
+	}
+
+	return
+}
@@ -0,0 +1,203 @@
+package acl
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"strings"
+)
+
+// Reports answer the questions auditors ask across every list in a
+// store at once: what can each user do to each file (the access
+// matrix), what can one user touch, and who can do something to one
+// file. A user's rights on a file are what check would give them:
+// their own entry, their groups', roles, inheritance and patterns,
+// less anything denied, as of now().
+
+// Format the access matrix can be printed in besides F_TEXT
+const F_CSV = "csv"
+
+// A user, or a file, and the rights that go with it in a report
+type Access struct {
+	Name   string
+	Rights Right
+}
+
+// e.g. "main.c orw"
+func (a Access) String() string {
+	return fmt.Sprintf("%s %s", a.Name, a.Rights)
+}
+
+// What every user can do to every file: Rights[u][f] is what Users[u]
+// has on Files[f]
+type AccessMatrix struct {
+	Users  []string
+	Files  []string
+	Rights [][]Right
+}
+
+// The rights a user really has on a file, 0 if none
+func (s *aclStore) rightsOf(filename, username string) right {
+	acl, ok := s.resolve(filename)
+	if !ok {
+		return 0
+	}
+	return acl.rightsOf(username)
+}
+
+// The rights a user really has by a list
+func (acl *accessControlList) rightsOf(username string) right {
+	d := acl.check(username, 0)
+	if len(d.entries) == 0 {
+		return 0
+	}
+	return d.granted &^ d.denied
+}
+
+// Every user with an entry in the lists, deny entries included, and
+// every member of a group with one, in the order they're first seen
+func (s *aclStore) users(lists []*accessControlList) (users []string) {
+	seen := make(map[string]bool)
+	add := func(user string) {
+		if !seen[user] {
+			seen[user] = true
+			users = append(users, user)
+		}
+	}
+
+	for _, acl := range lists {
+		for _, entry := range acl.ace.all() {
+			group, isGroup := entry.group()
+			if !isGroup {
+				add(entry.user)
+				continue
+			}
+			for _, member := range s.groups.members[group] {
+				add(member)
+			}
+		}
+	}
+
+	return
+}
+
+// The access matrix for every file the store has a list for, and
+// every user in those lists
+func (st *Store) Matrix() (m AccessMatrix) {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+
+	lists := make([]*accessControlList, 0, len(st.s.order))
+	for _, filename := range st.s.order {
+		lists = append(lists, st.s.lists[filename])
+	}
+
+	m.Users = st.s.users(lists)
+	m.Files = append([]string(nil), st.s.order...)
+	m.Rights = make([][]Right, len(m.Users))
+	for idx := range m.Users {
+		m.Rights[idx] = make([]Right, len(m.Files))
+	}
+
+	// Each file's list only has to be worked out once
+	for col, filename := range m.Files {
+		acl, _ := st.s.resolve(filename)
+		for row, user := range m.Users {
+			m.Rights[row][col] = acl.rightsOf(user)
+		}
+	}
+
+	return
+}
+
+// The matrix as CSV, a row per user and a column per file, with the
+// rights as letters. No rights is an empty cell.
+func (m AccessMatrix) CSV() (str string, err error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+
+	w.Write(append([]string{"user"}, m.Files...))
+	for idx, user := range m.Users {
+		record := []string{user}
+		for _, r := range m.Rights[idx] {
+			record = append(record, r.String())
+		}
+		w.Write(record)
+	}
+
+	w.Flush()
+	return buf.String(), w.Error()
+}
+
+// The matrix as a table lined up in columns, with '-' for no rights
+func (m AccessMatrix) String() (str string) {
+	cells := [][]string{append([]string{""}, m.Files...)}
+	for idx, user := range m.Users {
+		row := []string{user}
+		for _, r := range m.Rights[idx] {
+			if r == 0 {
+				row = append(row, "-")
+			} else {
+				row = append(row, r.String())
+			}
+		}
+		cells = append(cells, row)
+	}
+
+	widths := make([]int, len(cells[0]))
+	for _, row := range cells {
+		for col, cell := range row {
+			if len([]rune(cell)) > widths[col] {
+				widths[col] = len([]rune(cell))
+			}
+		}
+	}
+
+	for _, row := range cells {
+		line := ""
+		for col, cell := range row {
+			line += cell + strings.Repeat(" ", widths[col]-len([]rune(cell))+2)
+		}
+		str += strings.TrimRight(line, " ") + "\n"
+	}
+	return
+}
+
+// Does a user's rights count as having r? Any right at all does when
+// r is 0.
+func holds(has, r right) bool {
+	if r == 0 {
+		return has != 0
+	}
+	return has&r == r
+}
+
+// The files a user has every right in r on (any right, if r is 0),
+// with all the rights they have on each
+func (st *Store) FilesWhere(username string, r Right) (files []Access) {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+
+	for _, filename := range st.s.order {
+		if has := st.s.rightsOf(filename, username); holds(has, r) {
+			files = append(files, Access{filename, has})
+		}
+	}
+	return
+}
+
+// The users with every right in r on a file (any right, if r is 0),
+// with all the rights each has. The file doesn't need a list of its
+// own, see resolve.
+func (st *Store) UsersWith(filename string, r Right) (users []Access) {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+
+	rules, _ := st.s.rulesFor(filename)
+	for _, user := range st.s.users(rules) {
+		if has := st.s.rightsOf(filename, user); holds(has, r) {
+			users = append(users, Access{user, has})
+		}
+	}
+	return
+}
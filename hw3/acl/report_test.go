@@ -0,0 +1,50 @@
+package acl
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestReports(t *testing.T) {
+	SetMessages(ioutil.Discard)
+	defer SetMessages(os.Stdout)
+
+	store := NewStore()
+	for _, filename := range []string{"../acl12.txt", "../acl1.txt"} {
+		if err := store.ReadFile(filename, F_ACL); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m := store.Matrix()
+	want := "" +
+		"          ./    src/  src/lib/util.go  src/vendor  **/*.c  main.c\n" +
+		"root      orwx  orwx  orwx             -           orwx    orwx\n" +
+		"crenshaw  -     orwx  orwx             -           -       orwx\n" +
+		"vegdahl   -     rw    orw              -           -       rw\n" +
+		"mallory   -     -     -                -           -       -\n" +
+		"ubuntu    -     -     -                orwx        -       rw\n" +
+		"ec2-user  -     -     -                -           x       x\n"
+	if m.String() != want {
+		t.Errorf("Fail:\n%s", m)
+	}
+	if str, err := m.CSV(); err != nil || !strings.HasPrefix(str, "user,./,src/,src/lib/util.go,src/vendor,**/*.c,main.c\n"+
+		"root,orwx,orwx,orwx,,orwx,orwx\n") {
+		t.Errorf("Fail: %v\n%s", err, str)
+	}
+
+	// Every file vegdahl can write, and everyone who owns a file
+	// that only has lists above it
+	if files := store.FilesWhere("vegdahl", R_WRITE); fmt.Sprint(files) != "[src/ rw src/lib/util.go orw main.c rw]" {
+		t.Errorf("Fail: %v\n", files)
+	}
+	if users := store.UsersWith("src/lib/main.go", R_OWN); fmt.Sprint(users) != "[crenshaw orwx root orwx]" {
+		t.Errorf("Fail: %v\n", users)
+	}
+	if users := store.UsersWith("src/lib/main.go", 0); len(users) != 3 {
+		t.Errorf("Fail: %v\n", users)
+	}
+}
@@ -0,0 +1,208 @@
+package acl
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// A right that has a name and a letter, so it can be written out and
+// read back in without knowing its bit
+type rightDef struct {
+	name   string
+	letter rune
+	bit    right
+}
+
+// A rightRegistry is every right there is. The four built in rights
+// are always there; more can be defined with a rights file, and get
+// the bits above them in the order they're defined. Rights are looked
+// up by everything that runs at once, e.g. the server's handlers, so
+// the registry is locked while one is defined.
+type rightRegistry struct {
+	mu   sync.RWMutex
+	defs []rightDef
+}
+
+// The rights in use, see the -rights flag
+var registry = newRightRegistry()
+
+// Make a registry with just the built in rights, in the order
+// right.String() prints them
+func newRightRegistry() *rightRegistry {
+	return &rightRegistry{defs: []rightDef{
+		{"own", 'o', R_OWN},
+		{"read", 'r', R_READ},
+		{"write", 'w', R_WRITE},
+		{"exec", 'x', R_EXEC},
+	}}
+}
+
+// Define a new right, giving it the next free bit.
+func (reg *rightRegistry) define(name string, letter rune) (def rightDef, err error) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	for _, other := range reg.defs {
+		switch {
+		case other.name == name:
+			return def, fmt.Errorf("right %s is defined twice", name)
+		case other.letter == letter:
+			return def, fmt.Errorf("rights %s and %s both use the letter '%c'",
+				other.name, name, letter)
+		}
+	}
+
+	// Names can't be mistaken for a number, letters or a list of names
+	if _, err := strconv.Atoi(name); err == nil || utf8.RuneCountInString(name) < 2 ||
+		strings.Contains(name, ",") {
+		return def, fmt.Errorf("%q isn't a name a right can have", name)
+	}
+
+	bit := reg.bits() + 1
+	if bit == 0 {
+		return def, fmt.Errorf("no room for right %s, there are too many", name)
+	}
+
+	def = rightDef{name, letter, bit}
+	reg.defs = append(reg.defs, def)
+	return def, nil
+}
+
+// Every right in the registry
+func (reg *rightRegistry) all() right {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	return reg.bits()
+}
+
+// Same as all, for when the registry is already locked
+func (reg *rightRegistry) bits() (r right) {
+	for _, def := range reg.defs {
+		r |= def.bit
+	}
+	return
+}
+
+// Every right's definition, in the order they were defined
+func (reg *rightRegistry) list() []rightDef {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	return append([]rightDef(nil), reg.defs...)
+}
+
+// Look up a right by name or by letter
+func (reg *rightRegistry) lookup(name string) (def rightDef, ok bool) {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+
+	for _, def := range reg.defs {
+		if def.name == name || string(def.letter) == name {
+			return def, true
+		}
+	}
+	return def, false
+}
+
+// Read a rights file into the registry. Each line defines one right
+// with its name and the letter it prints as:
+//
+//	delete d
+//	append a
+//
+// The built in rights (own, read, write and exec) don't need to be
+// defined.
+func parseRightsFile(filename string) (err error) {
+	lines, err := lexFile(filename)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(messages(), "%s was successfully opened.\nParsing rights from file.\n", filename)
+
+	var diags diagnostics
+
+	for _, line := range lines {
+		if len(line) == 0 {
+			continue
+		}
+		if len(line) != 2 {
+			diags.add(line[0], D_SYNTAX, "expected a right's name and its letter")
+			continue
+		}
+
+		letter, size := utf8.DecodeRuneInString(line[1].text)
+		if size != len(line[1].text) {
+			diags.add(line[1], D_SYNTAX, "a right's letter has to be a single letter")
+			continue
+		}
+
+		if _, err := registry.define(line[0].text, letter); err != nil {
+			diags.add(line[0], D_BAD_RIGHT, "%v", err)
+		}
+	}
+
+	return diags.err()
+}
+
+// Parse rights written as a number (6), as letters (rw), or as names
+// joined with commas (read,write). Whichever way, every right has to
+// be in the registry. An empty string isn't any rights at all.
+func parseRights(str string) (r right, err error) {
+	if str == "" {
+		return 0, errors.New("no rights given")
+	}
+	if n, err := strconv.ParseUint(str, 10, 32); err == nil {
+		r = right(n)
+		if !r.valid() {
+			return 0, fmt.Errorf("%d has bits that aren't rights", n)
+		}
+		return r, nil
+	}
+
+	// A single name, or a list of them. Names are tried before
+	// letters, so a right named 'rw' would win over 'r' and 'w'.
+	if def, ok := registry.lookup(str); ok && utf8.RuneCountInString(str) > 1 {
+		return def.bit, nil
+	}
+	if strings.Contains(str, ",") {
+		for _, name := range strings.Split(str, ",") {
+			def, ok := registry.lookup(name)
+			if !ok {
+				return 0, fmt.Errorf("unknown right %q in %q", name, str)
+			}
+			r |= def.bit
+		}
+		return r, nil
+	}
+
+	// A word that isn't all letters of rights was more likely meant
+	// as a name
+	for _, letter := range str {
+		if _, ok := registry.lookup(string(letter)); !ok && utf8.RuneCountInString(str) > 1 {
+			return 0, fmt.Errorf("unknown right %q", str)
+		}
+	}
+
+	return parseLetters(str)
+}
+
+// Turn the letters right.String() makes back into a right.
+// Letters may come in any order, but each only once.
+func parseLetters(str string) (r right, err error) {
+	for _, letter := range str {
+		def, ok := registry.lookup(string(letter))
+		if !ok {
+			return 0, fmt.Errorf("unknown right '%c' in %q", letter, str)
+		}
+
+		if r&def.bit != 0 {
+			return 0, fmt.Errorf("right '%c' given twice in %q", letter, str)
+		}
+		r |= def.bit
+	}
+
+	return r, nil
+}
@@ -0,0 +1,43 @@
+package acl
+
+import (
+	"testing"
+)
+
+func TestRights(t *testing.T) {
+	defer func() { registry = newRightRegistry() }()
+
+	// 16 used to pass as valid, but isn't a right until one is defined
+	if right(16).valid() || right(6).validSingle() || !R_ALL.valid() {
+		t.Errorf("Fail: built in rights\n")
+	}
+
+	if err := parseRightsFile("../rights1.txt"); err != nil {
+		t.Fatal(err)
+	}
+	del, _ := registry.lookup("delete")
+	if del.bit != 16 || !right(16).validSingle() || right(128).valid() {
+		t.Errorf("Fail: %v\n", registry.defs)
+	}
+
+	for str, want := range map[string]right{
+		"6": R_READ | R_WRITE, "rw": R_READ | R_WRITE, "dr": R_READ | 16,
+		"chmod": 64, "read,append": R_READ | 32,
+	} {
+		if r, err := parseRights(str); err != nil || r != want {
+			t.Errorf("Fail: %s is %d, %v\n", str, r, err)
+		}
+	}
+	for _, str := range []string{"128", "rr", "z", "read,nope", "-1", ""} {
+		if _, err := parseRights(str); err == nil {
+			t.Errorf("Fail: %s parsed\n", str)
+		}
+	}
+
+	if s := (R_OWN | 32 | R_EXEC).String(); s != "oxa" {
+		t.Errorf("Fail: %s\n", s)
+	}
+	if _, err := registry.define("remove", 'd'); err == nil {
+		t.Errorf("Fail: letter used twice\n")
+	}
+}
@@ -0,0 +1,260 @@
+package acl
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// A role is a named set of rights, like 'reviewer' for rw, which an
+// entry can hold instead of (or as well as) rights of its own. A role
+// may inherit other roles, getting their rights too, so 'maintainer'
+// can be everything 'reviewer' is and then some.
+//
+// Roles are defined in a roles file (see the -roles flag), and an
+// entry names them after its rights with a '%', e.g.
+// '* vegdahl 0 %maintainer', or with the role in place of the rights,
+// '* vegdahl %maintainer'. An entry's rights when checking, printing
+// and the like are its own plus those of every role it holds, see
+// effective.
+
+// Entries name their roles with this in front, e.g. '%maintainer'
+const rolePrefix = "%"
+
+// A role and the roles it inherits from
+type roleDef struct {
+	name     string
+	rights   right
+	inherits []string
+}
+
+// Every role there is, in the order defined. Locked while one is
+// defined, like the rights (see rightRegistry).
+type roleTable struct {
+	mu    sync.RWMutex
+	defs  map[string]*roleDef
+	order []string
+}
+
+// The roles in use, see the -roles flag
+var roles = newRoleTable()
+
+// Make an empty role table
+func newRoleTable() *roleTable {
+	return &roleTable{defs: make(map[string]*roleDef)}
+}
+
+// Define a role. The roles it inherits have to be defined already,
+// which keeps a role from ending up inheriting itself.
+func (rt *roleTable) define(name string, r right, inherits []string) (err error) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	if _, found := rt.defs[name]; found {
+		return fmt.Errorf("role %s is defined twice", name)
+	}
+	if name == "" || strings.HasPrefix(name, rolePrefix) || strings.ContainsAny(name, ",:") ||
+		strings.IndexFunc(name, unicode.IsSpace) >= 0 {
+		return fmt.Errorf("%q isn't a name a role can have", name)
+	}
+	if !r.valid() {
+		return fmt.Errorf("role %s has bits that aren't rights", name)
+	}
+
+	for _, parent := range inherits {
+		if _, found := rt.defs[parent]; !found {
+			return fmt.Errorf("role %s inherits %s, which isn't defined (yet)", name, parent)
+		}
+	}
+
+	rt.defs[name] = &roleDef{name, r, inherits}
+	rt.order = append(rt.order, name)
+	return nil
+}
+
+// Is a role defined?
+func (rt *roleTable) defined(name string) bool {
+	rt.mu.RLock()
+	defer rt.mu.RUnlock()
+
+	_, found := rt.defs[name]
+	return found
+}
+
+// The rights of a role along with everything it inherits. Undefined
+// roles have no rights.
+func (rt *roleTable) expand(name string) right {
+	rt.mu.RLock()
+	defer rt.mu.RUnlock()
+	return rt.rightsOf(name)
+}
+
+// Same as expand, for when the table is already locked
+func (rt *roleTable) rightsOf(name string) (r right) {
+	def, found := rt.defs[name]
+	if !found {
+		return 0
+	}
+
+	r = def.rights
+	for _, parent := range def.inherits {
+		r |= rt.rightsOf(parent)
+	}
+	return
+}
+
+// Every role's name, in the order they were defined
+func (rt *roleTable) names() []string {
+	rt.mu.RLock()
+	defer rt.mu.RUnlock()
+	return append([]string(nil), rt.order...)
+}
+
+// Read a roles file. Each line defines one role: its name, its rights,
+// and the roles it inherits, if any:
+//
+//	reader      r
+//	reviewer    w    reader
+//	maintainer  ox   reviewer
+//
+// Roles have to be defined before they're inherited.
+func parseRolesFile(filename string) (err error) {
+	lines, err := lexFile(filename)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(messages(), "%s was successfully opened.\nParsing roles from file.\n", filename)
+
+	var diags diagnostics
+
+	for _, line := range lines {
+		if len(line) == 0 {
+			continue
+		}
+		if len(line) < 2 {
+			diags.add(line[0], D_SYNTAX, "expected a role's name and its rights")
+			continue
+		}
+
+		r, err := parseRight(line[1])
+		if err != nil {
+			diags.add(line[1], D_BAD_RIGHT, "%v", err)
+			continue
+		}
+
+		var inherits []string
+		for _, parent := range line[2:] {
+			inherits = append(inherits, strings.TrimPrefix(parent.text, rolePrefix))
+		}
+
+		if err := roles.define(line[0].text, r, inherits); err != nil {
+			diags.add(line[0], D_UNKNOWN_ROLE, "%v", err)
+		}
+	}
+
+	return diags.err()
+}
+
+// Every right the entry has: its own and those of its roles
+func (e accessControlEntry) effective() right {
+	r := e.rights
+	for _, role := range e.roles {
+		r |= roles.expand(role)
+	}
+	return r
+}
+
+// Does the entry hold a role itself? Roles it only inherits don't count.
+func (e accessControlEntry) hasRole(role string) bool {
+	for _, held := range e.roles {
+		if held == role {
+			return true
+		}
+	}
+	return false
+}
+
+// The entry's roles as an ACL file has them, e.g. " %reviewer %reader",
+// or "" if it has none
+func (e accessControlEntry) roleNames() (str string) {
+	for _, role := range e.roles {
+		str += " " + rolePrefix + role
+	}
+	return
+}
+
+// Give a user a role in an ACL. A user without an entry gets one, with
+// no rights but the role's. ok is false if they already hold it.
+func (acl *accessControlList) addRole(role, username string) (ok bool) {
+	idx := acl.indexOf(username, false)
+	if idx < 0 {
+		acl.addEntry(username, 0)
+		idx = acl.indexOf(username, false)
+	}
+
+	entry := acl.ace.at(idx)
+	if entry.hasRole(role) {
+		return false
+	}
+
+	// A new slice, so lists cloned from this one don't see it
+	changed := entry
+	changed.roles = append(append([]string(nil), entry.roles...), role)
+	acl.ace.set(idx, changed)
+
+	// The role may have made them an owner
+	if !entry.isOwner() && changed.isOwner() {
+		acl.reorder(idx)
+	}
+	return true
+}
+
+// Take a role away from a user in an ACL. Their entry stays, even if
+// it's left with no rights, as with deleteRight. ok is false if they
+// don't hold the role.
+func (acl *accessControlList) deleteRole(role, username string) (ok bool) {
+	idx := acl.indexOf(username, false)
+	if idx < 0 || !acl.ace.at(idx).hasRole(role) {
+		return false
+	}
+
+	entry := acl.ace.at(idx)
+	var kept []string
+	for _, held := range entry.roles {
+		if held != role {
+			kept = append(kept, held)
+		}
+	}
+	changed := entry
+	changed.roles = kept
+	acl.ace.set(idx, changed)
+
+	if entry.isOwner() && !changed.isOwner() {
+		acl.reorder(idx)
+	}
+	return true
+}
+
+// Are two lists of roles the same, in the same order?
+func sameRoles(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for idx := range a {
+		if a[idx] != b[idx] {
+			return false
+		}
+	}
+	return true
+}
+
+// The roles in a that aren't in b
+func missingRoles(a, b []string) (missing []string) {
+	for _, role := range a {
+		if !(accessControlEntry{roles: b}).hasRole(role) {
+			missing = append(missing, role)
+		}
+	}
+	return
+}
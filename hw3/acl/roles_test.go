@@ -0,0 +1,107 @@
+package acl
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRoles(t *testing.T) {
+	SetMessages(ioutil.Discard)
+	defer SetMessages(os.Stdout)
+	defer func() { roles = newRoleTable() }()
+
+	if err := parseRolesFile("../roles1.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if r := roles.expand("maintainer"); r != R_ALL {
+		t.Errorf("Fail: maintainer is %s\n", r)
+	}
+	for name, inherits := range map[string][]string{"reviewer": nil, "boss": {"nope"}, "%x": nil} {
+		if err := roles.define(name, R_READ, inherits); err == nil {
+			t.Errorf("Fail: %s defined\n", name)
+		}
+	}
+
+	acls = newACLStore()
+	if err := acls.parseInputFile("../acl11.txt"); err != nil {
+		t.Fatal(err)
+	}
+	before := acls.clone()
+
+	// Roles count when checking, and owners from roles go first
+	want := "printList: (File: main.c. , vegdahl (orwx %maintainer), crenshaw (orwx), " +
+		"ubuntu (rw %reviewer), ec2-user (x), deny mallory (w)) \n"
+	if got := acls.String(); got != want {
+		t.Errorf("Fail: %s\n", got)
+	}
+	if d := acls.check("main.c", "ubuntu", R_READ|R_WRITE); !d.allowed {
+		t.Errorf("Fail: %v\n", d)
+	}
+
+	if err := acls.parseCommandFile("../commands12.txt", "", ""); err != nil {
+		t.Fatal(err)
+	}
+	want = "printList: (File: main.c. , ubuntu (orwx %maintainer), crenshaw (orwx), " +
+		"vegdahl (), ec2-user (rx %reader), deny mallory (w)) \n"
+	if got := acls.String(); got != want {
+		t.Errorf("Fail: %s\n", got)
+	}
+
+	// Diffs give and take roles
+	str, _ := diffStores(acls, before).commands()
+	cmds, diags := parseCommands(flatten(lex("diff.txt", str)))
+	runner := newCommandRunner(acls)
+	for _, cmd := range cmds {
+		runner.run(cmd)
+	}
+	if len(diags) > 0 || len(runner.diags) > 0 || !diffStores(acls, before).empty() {
+		t.Errorf("Fail: %v %v\n%s%v\n", diags, runner.diags, str, acls)
+	}
+
+	// Problems with roles, and undoing them
+	cmds, _ = parseCommands(flatten(lex("test.txt",
+		"assign alice nope\nassign ubuntu reviewer\nrevoke crenshaw reader\nrevoke ubuntu reviewer\nundo 1")))
+	runner = newCommandRunner(acls)
+	for _, cmd := range cmds {
+		runner.run(cmd)
+	}
+	if len(runner.diags) != 3 || runner.diags[0].kind != D_UNKNOWN_ROLE ||
+		runner.diags[1].kind != D_DUPLICATE || runner.diags[2].kind != D_UNKNOWN_ROLE {
+		t.Errorf("Fail: %v\n", runner.diags)
+	}
+	if e, _ := acls.lists["main.c"].findEntry("ubuntu", false); !e.hasRole("reviewer") {
+		t.Errorf("Fail: %v\n", acls)
+	}
+	// The log has the rights the roles give
+	if got := runner.log[0].String(); !strings.HasSuffix(got, "revoke main.c ubuntu rw %reviewer -> r") {
+		t.Errorf("Fail: %s\n", got)
+	}
+
+	// Roles go through every format that can hold them
+	for _, format := range []string{F_ACL, F_JSON, F_YAML} {
+		str, err := acls.format(format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		filename := filepath.Join(t.TempDir(), "acl."+format)
+		ioutil.WriteFile(filename, []byte(str), 0644)
+
+		reread := newACLStore()
+		if err := reread.parseFileAs(filename, format); err != nil {
+			t.Fatal(err)
+		}
+		if !diffStores(acls, reread).empty() {
+			t.Errorf("Fail: %s\n%v\n", format, reread)
+		}
+	}
+
+	bad := filepath.Join(t.TempDir(), "acl.txt")
+	ioutil.WriteFile(bad, []byte(": main.c\n* alice 15 %nope\n- bob %reader\n"), 0644)
+	if err := newACLStore().parseInputFile(bad); err == nil || len(err.(diagnostics)) != 2 ||
+		err.(diagnostics)[0].kind != D_UNKNOWN_ROLE {
+		t.Errorf("Fail: %v\n", err)
+	}
+}
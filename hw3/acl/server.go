@@ -0,0 +1,187 @@
+package acl
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// Handler serves a store over HTTP, with JSON going both ways:
+//
+//	GET  /lists                      every list, as the JSON format has it
+//	GET  /lists?file=main.c          the list that applies to a file
+//	GET  /check?file=main.c&user=vegdahl&rights=rw
+//	POST /grant         {"file": "main.c", "user": "vegdahl", "rights": "r"}
+//	POST /revoke        {"file": "main.c", "user": "vegdahl", "rights": 4}
+//	POST /delete-entry  {"file": "main.c", "user": "vegdahl"}
+//
+// Rights may be a number or a string, written any way command files
+// take them. Changes may say who is making them with "as", in which
+// case the ownership rules of command files apply (see owners.go);
+// without it, they're made as an administrator. A change answers with
+// the list as it is afterwards.
+//
+// Problems are answered with {"error": "..."} and a status that says
+// what kind of problem it was, e.g. 404 for an unknown file or user.
+type Handler struct {
+	store *Store
+	mux   *http.ServeMux
+}
+
+// Make a handler for a store
+func NewHandler(store *Store) *Handler {
+	h := &Handler{store: store, mux: http.NewServeMux()}
+
+	h.mux.HandleFunc("/lists", h.lists)
+	h.mux.HandleFunc("/check", h.check)
+	h.mux.HandleFunc("/grant", h.change(func(req changeRequest) error {
+		if req.Rights == 0 {
+			return diagnostic{kind: D_BAD_RIGHT, msg: "rights are needed"}
+		}
+		return store.Grant(req.File, req.User, right(req.Rights), req.As)
+	}))
+	h.mux.HandleFunc("/revoke", h.change(func(req changeRequest) error {
+		if req.Rights == 0 {
+			return diagnostic{kind: D_BAD_RIGHT, msg: "rights are needed"}
+		}
+		return store.Revoke(req.File, req.User, right(req.Rights), req.As)
+	}))
+	h.mux.HandleFunc("/delete-entry", h.change(func(req changeRequest) error {
+		return store.DeleteEntry(req.File, req.User, req.As)
+	}))
+
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+// The body of a grant, revoke or delete-entry request
+type changeRequest struct {
+	File   string    `json:"file"`
+	User   string    `json:"user"`
+	Rights jsonRight `json:"rights"`
+	As     string    `json:"as"`
+}
+
+// Rights in a request, which may be a number or a string
+type jsonRight right
+
+func (r *jsonRight) UnmarshalJSON(data []byte) (err error) {
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		// Not a string, so it had better be a number
+		var n uint32
+		if err := json.Unmarshal(data, &n); err != nil {
+			return fmt.Errorf("rights have to be a number or a string, not %s", data)
+		}
+		str = strconv.FormatUint(uint64(n), 10)
+	}
+
+	rights, err := parseRights(str)
+	*r = jsonRight(rights)
+	return
+}
+
+// The answer to a check
+type checkResponse struct {
+	Allowed bool   `json:"allowed"`
+	Reason  string `json:"reason"`
+}
+
+// Write a value as JSON with a status
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+// Write a problem as JSON, with a status for its kind
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusBadRequest
+	if d, ok := err.(diagnostic); ok {
+		switch d.kind {
+		case D_UNKNOWN_FILE, D_UNKNOWN_USER:
+			status = http.StatusNotFound
+		case D_DENIED:
+			status = http.StatusForbidden
+		case D_DUPLICATE, D_INVARIANT:
+			status = http.StatusConflict
+		}
+	}
+
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+func (h *Handler) lists(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	h.store.mu.RLock()
+	defer h.store.mu.RUnlock()
+
+	filename := r.URL.Query().Get("file")
+	if filename == "" {
+		writeJSON(w, http.StatusOK, h.store.s.export())
+		return
+	}
+
+	acl, ok := h.store.s.resolve(filename)
+	if !ok {
+		writeError(w, diagnostic{kind: D_UNKNOWN_FILE, token: filename,
+			msg: "no access control list for file"})
+		return
+	}
+	writeJSON(w, http.StatusOK, acl.export())
+}
+
+func (h *Handler) check(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	rights, err := parseRights(query.Get("rights"))
+	if err != nil {
+		writeError(w, diagnostic{kind: D_BAD_RIGHT, token: query.Get("rights"), msg: err.Error()})
+		return
+	}
+
+	allowed, why := h.store.Check(query.Get("file"), query.Get("user"), rights)
+	writeJSON(w, http.StatusOK, checkResponse{allowed, why})
+}
+
+// Handle a change, answering with the list it changed
+func (h *Handler) change(do func(req changeRequest) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req changeRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, diagnostic{kind: D_SYNTAX, msg: err.Error()})
+			return
+		}
+		if req.File == "" || req.User == "" {
+			writeError(w, diagnostic{kind: D_SYNTAX, msg: "file and user are both needed"})
+			return
+		}
+
+		if err := do(req); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		h.store.mu.RLock()
+		defer h.store.mu.RUnlock()
+		acl, _ := h.store.s.lookup(req.File)
+		writeJSON(w, http.StatusOK, acl.export())
+	}
+}
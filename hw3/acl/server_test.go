@@ -0,0 +1,86 @@
+package acl
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServer(t *testing.T) {
+	store := NewStore()
+	if err := store.ReadFile("../acl1.txt", F_ACL); err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(NewHandler(store))
+	defer server.Close()
+
+	post := func(path, body string) (status int, reply map[string]interface{}) {
+		resp, err := http.Post(server.URL+path, "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		json.NewDecoder(resp.Body).Decode(&reply)
+		return resp.StatusCode, reply
+	}
+	get := func(path string) (status int, reply map[string]interface{}) {
+		resp, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		json.NewDecoder(resp.Body).Decode(&reply)
+		return resp.StatusCode, reply
+	}
+
+	if status, reply := get("/check?file=main.c&user=ubuntu&rights=x"); status != 200 || reply["allowed"] != false {
+		t.Errorf("Fail: %d %v\n", status, reply)
+	}
+	if status, reply := post("/grant", `{"file": "main.c", "user": "ubuntu", "rights": "x"}`); status != 200 {
+		t.Errorf("Fail: %d %v\n", status, reply)
+	}
+	if status, reply := get("/check?file=main.c&user=ubuntu&rights=rwx"); status != 200 || reply["allowed"] != true {
+		t.Errorf("Fail: %d %v\n", status, reply)
+	}
+
+	// New users get an entry, and rights may be numbers
+	if status, reply := post("/grant", `{"file": "main.c", "user": "alice", "rights": 6}`); status != 200 ||
+		len(reply["entries"].([]interface{})) != 5 {
+		t.Errorf("Fail: %d %v\n", status, reply)
+	}
+	if status, reply := post("/revoke", `{"file": "main.c", "user": "alice", "rights": "w"}`); status != 200 {
+		t.Errorf("Fail: %d %v\n", status, reply)
+	}
+	if status, reply := post("/delete-entry", `{"file": "main.c", "user": "alice"}`); status != 200 {
+		t.Errorf("Fail: %d %v\n", status, reply)
+	}
+
+	// Problems come back with a status for their kind, and change nothing
+	for _, c := range []struct {
+		path, body string
+		status     int
+	}{
+		{"/delete-entry", `{"file": "main.c", "user": "alice"}`, 404},
+		{"/grant", `{"file": "nope.c", "user": "alice", "rights": "r"}`, 404},
+		{"/grant", `{"file": "main.c", "user": "ubuntu", "rights": "q"}`, 400},
+		{"/revoke", `{"file": "main.c", "user": "crenshaw", "rights": "o"}`, 409},
+		{"/grant", `{"file": "main.c", "user": "ubuntu", "rights": "o", "as": "vegdahl"}`, 403},
+		{"/grant", `{"file": "main.c", "user": "newbie"}`, 400},
+		{"/grant", `{"file": "main.c", "user": "newbie", "rights": ""}`, 400},
+		{"/revoke", `{"file": "main.c", "user": "ubuntu", "rights": 0}`, 400},
+	} {
+		if status, reply := post(c.path, c.body); status != c.status {
+			t.Errorf("Fail: %s %s: %d %v\n", c.path, c.body, status, reply)
+		}
+	}
+
+	if status, reply := get("/check?file=main.c&user=vegdahl"); status != 400 {
+		t.Errorf("Fail: %d %v\n", status, reply)
+	}
+
+	if status, reply := get("/lists?file=main.c"); status != 200 || len(reply["entries"].([]interface{})) != 4 {
+		t.Errorf("Fail: %d %v\n", status, reply)
+	}
+}
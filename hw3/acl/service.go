@@ -0,0 +1,283 @@
+package acl
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+// A set of rights, e.g. R_READ|R_WRITE, for users of the package
+type Right = right
+
+// A Store holds the access control lists of any number of files, and
+// the groups they share. It's safe to use from many goroutines: any
+// number of them may read it at once, while changes are made one at a
+// time.
+//
+// Every change is made to a copy that only replaces the lists once the
+// whole change worked, as command files are, so a change that fails
+// (or breaks the ownership rules, see owners.go) changes nothing.
+type Store struct {
+	mu sync.RWMutex
+	s  *aclStore
+}
+
+// Make a new, empty store
+func NewStore() *Store {
+	return &Store{s: newACLStore()}
+}
+
+// Wrap an aclStore for use by more than one goroutine
+func newStore(s *aclStore) *Store {
+	return &Store{s: s}
+}
+
+// Print the store's messages about what's going on to w, rather than
+// wherever SetMessages says
+func (st *Store) SetMessages(w io.Writer) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.s.msgs = w
+}
+
+// Set the most owners a list may have, 0 for no limit
+func (st *Store) SetMaxOwners(n int) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.s.maxOwners = n
+}
+
+// Read the lists in a file into the store. format is one of F_ACL,
+// F_JSON, F_YAML, F_FACL or F_DIR (for a directory tree). Problems
+// with the file are returned as one error, with a line for each.
+func (st *Store) ReadFile(filename, format string) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.s.parseFileAs(filename, format)
+}
+
+// Read a file of group definitions into the store
+func (st *Store) ReadGroupFile(filename string) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.s.parseGroupFile(filename)
+}
+
+// Check the names entries and commands use against a user directory,
+// read from a file in /etc/passwd format and one in /etc/group format
+// (see directory.go). Either may be "" to not check that kind of name.
+// Unknown names are rejected from then on, unless SetRejectUnknown
+// says otherwise.
+func (st *Store) ReadUserDirectory(passwdFile, groupFile string) error {
+	ud, err := readUserDirectory(passwdFile, groupFile)
+	if err != nil {
+		return err
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.s.directory, st.s.rejectUnknown = ud, true
+	return nil
+}
+
+// Reject entries and commands naming users or groups that aren't in
+// the user directory, or just warn about them
+func (st *Store) SetRejectUnknown(reject bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.s.rejectUnknown = reject
+}
+
+// Define the rights in a rights file (see rights.go) for every store.
+// Rights have to be defined before any store uses them.
+func ReadRightsFile(filename string) error {
+	return parseRightsFile(filename)
+}
+
+// Define the roles in a roles file (see roles.go) for every store.
+// Roles, like rights, have to be defined before any store uses them.
+func ReadRolesFile(filename string) error {
+	return parseRolesFile(filename)
+}
+
+// Parse rights written as a number, letters or names, like '6', 'rw'
+// or 'read,write'
+func ParseRights(str string) (r Right, err error) {
+	return parseRights(str)
+}
+
+// Stringify the store in a format: F_TEXT, F_ACL, F_JSON, F_YAML or F_FACL
+func (st *Store) Format(format string) (str string, err error) {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	return st.s.format(format)
+}
+
+func (st *Store) String() string {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	return st.s.String()
+}
+
+// Write the store to a file in a format, replacing the file all at
+// once (see writeFileAtomic)
+func (st *Store) WriteFile(filename, format string) (err error) {
+	str, err := st.Format(format)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filename, []byte(str))
+}
+
+// The files the store has lists for, in the order they were added
+func (st *Store) Files() []string {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	return append([]string(nil), st.s.order...)
+}
+
+// Does user hold every right in r on a file? why says how that was
+// decided, e.g. 'allow: vegdahl wants r (4) on main.c, vegdahl grants rw'.
+// Patterns count, see glob.go.
+func (st *Store) Check(filename, user string, r Right) (allowed bool, why string) {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+
+	d := st.s.check(filename, user, r)
+	return d.allowed, d.String()
+}
+
+// Run a command file against the store as principal ("" for an
+// administrator, see owners.go). If any command fails nothing changes,
+// and the problems are returned. Changes are appended to auditLog,
+// unless it's "", and nothing changes if that can't be done either.
+func (st *Store) RunCommandFile(filename, principal, auditLog string) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.s.parseCommandFile(filename, principal, auditLog)
+}
+
+// Same as RunCommandFile, but the store is left alone and what it
+// would have become is returned instead
+func (st *Store) DryRun(filename, principal string) (after *Store, err error) {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+
+	runner, err := st.s.runCommandFile(filename, principal)
+	if err != nil {
+		return nil, err
+	}
+	return newStore(runner.store), nil
+}
+
+// Run the commands build makes against the store as principal,
+// keeping them only if every one works. build is called with the
+// store locked, so it can look at the lists to decide what to do.
+// Only the first problem is returned.
+func (st *Store) run(principal string, build func(s *aclStore) ([]command, error)) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	cmds, err := build(st.s)
+	if err != nil {
+		return err
+	}
+
+	runner := newCommandRunner(st.s.clone())
+	runner.actAs(principal, true)
+	for _, cmd := range cmds {
+		runner.run(cmd)
+		if len(runner.diags) > 0 {
+			return runner.diags[0]
+		}
+	}
+
+	st.s = runner.store
+	return nil
+}
+
+// Make a command as if it had been read from a file, but with no
+// position
+func makeCommand(verb string, r right, args ...string) (cmd command) {
+	cmd = command{verb: token{text: verb}, rights: r}
+	for _, arg := range args {
+		cmd.args = append(cmd.args, token{text: arg})
+	}
+	return
+}
+
+// The commands that change a user's rights on a file one right at a
+// time, with bits that aren't rights left over for verb to complain
+// about
+func rightCommands(filename, verb, user string, r right) []command {
+	cmds := []command{makeCommand("file", 0, filename)}
+	for _, single := range r.singles() {
+		cmds = append(cmds, makeCommand(verb, single, user, single.String()))
+	}
+	if !r.valid() {
+		cmds = append(cmds, makeCommand(verb, r, user, fmt.Sprint(uint32(r))))
+	}
+	return cmds
+}
+
+// Give a user rights on a file, as principal. Each right is added with
+// addRight, as 'ar' does, or if the user has no entry yet, they get
+// one with every right in r, as 'ae' does.
+func (st *Store) Grant(filename, user string, r Right, principal string) error {
+	return st.run(principal, func(s *aclStore) ([]command, error) {
+		if acl, ok := s.lookup(filename); ok && acl.indexOf(user, false) < 0 {
+			return []command{makeCommand("file", 0, filename),
+				makeCommand("ae", r, user, fmt.Sprint(uint32(r)))}, nil
+		}
+		return rightCommands(filename, "ar", user, r), nil
+	})
+}
+
+// Take rights away from a user on a file, as principal, with
+// deleteRight as 'dr' does
+func (st *Store) Revoke(filename, user string, r Right, principal string) error {
+	return st.run(principal, func(s *aclStore) ([]command, error) {
+		return rightCommands(filename, "dr", user, r), nil
+	})
+}
+
+// Delete a user's entry on a file, as principal, with deleteEntry as
+// 'de' does. Unlike 'de', a user without an entry is a problem.
+func (st *Store) DeleteEntry(filename, user, principal string) error {
+	return st.run(principal, func(s *aclStore) ([]command, error) {
+		if acl, ok := s.lookup(filename); ok && acl.indexOf(user, false) < 0 {
+			return nil, diagnostic{kind: D_UNKNOWN_USER, token: user,
+				msg: fmt.Sprintf("no matching entry on file %s", filename)}
+		}
+		return []command{makeCommand("file", 0, filename), makeCommand("de", 0, user)}, nil
+	})
+}
+
+// Print what changed from one store to another
+func PrintChanges(before, after *Store) {
+	before.mu.RLock()
+	defer before.mu.RUnlock()
+	after.mu.RLock()
+	defer after.mu.RUnlock()
+
+	printChanges(diffStores(before.s, after.s))
+}
+
+// A command file that turns one store into another, and what it can't
+// do, a line each: files that are only in one of them, and time limits
+// commands can't set
+func DiffCommands(before, after *Store) (cmds string, skipped []string) {
+	before.mu.RLock()
+	defer before.mu.RUnlock()
+	after.mu.RLock()
+	defer after.mu.RUnlock()
+
+	return diffStores(before.s, after.s).commands()
+}
+
+// Work out the mode bits of every file under root, see posix.go
+func (st *Store) PlanModes(root string) (plan ModePlan, err error) {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	return st.s.planModes(root)
+}
@@ -0,0 +1,74 @@
+package acl
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestStoreConcurrency(t *testing.T) {
+	SetMessages(ioutil.Discard)
+	defer SetMessages(os.Stdout)
+
+	store := NewStore()
+	if err := store.ReadFile("../acl1.txt", F_ACL); err != nil {
+		t.Fatal(err)
+	}
+
+	// Readers and writers at once, for the race detector to look at
+	var wg sync.WaitGroup
+	for idx := 0; idx < 8; idx++ {
+		wg.Add(2)
+		user := fmt.Sprintf("user%d", idx)
+		go func() {
+			defer wg.Done()
+			for n := 0; n < 20; n++ {
+				store.Grant("main.c", user, R_READ, "")
+				store.Revoke("main.c", user, R_READ, "")
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for n := 0; n < 20; n++ {
+				store.Check("main.c", "vegdahl", R_READ)
+				store.Format(F_JSON)
+			}
+		}()
+	}
+
+	// And a shell, and files read on their own to be signed, which
+	// print as they go
+	signed := filepath.Join(t.TempDir(), "acl.txt")
+	if err := store.WriteFile(signed, F_ACL); err != nil {
+		t.Fatal(err)
+	}
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		lines := strings.Repeat("file main.c\nar vegdahl x\ndr vegdahl x\n", 20)
+		if err := store.Shell(strings.NewReader(lines), ioutil.Discard, ShellOptions{}); err != nil {
+			t.Error(err)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for n := 0; n < 20; n++ {
+			if _, err := SignFile(signed, F_ACL, []byte("key")); err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+	wg.Wait()
+
+	if allowed, why := store.Check("main.c", "user3", R_READ); allowed {
+		t.Errorf("Fail: %s\n", why)
+	}
+	// Everyone got an entry, and has nothing left in it
+	if strings.Count(store.String(), " ()") != 8 {
+		t.Errorf("Fail: %v\n", store)
+	}
+}
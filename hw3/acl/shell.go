@@ -0,0 +1,316 @@
+package acl
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// The shell takes the same commands command files do, one or more to
+// a line, and runs each line as soon as it's entered. It has a few
+// commands of its own, too:
+var shellHelp = map[string]string{
+	"show":  "show [<file> | all]: print the list being worked on, a file's list, or every list",
+	"check": "check <user> <rights>: the same as ck",
+	"save":  "save [<file>]: write the lists out, to the file given or the one they came from",
+	"help":  "help [<command>]: list the commands, or say what one does",
+	"quit":  "quit: leave the shell, without saving",
+}
+
+// Where a shell saves to, and who it runs commands as
+type ShellOptions struct {
+	// The user commands are run as, see owners.go. "" for an
+	// administrator.
+	Principal string
+	// Where and how 'save' writes the lists, when it isn't given a file
+	SaveTo     string
+	SaveFormat string
+	// File each save appends the changes made since the last one to,
+	// or "" for none
+	AuditLog string
+	// Key to sign saved files with, see sign.go, or nil to not sign them
+	Key []byte
+}
+
+// A shell working on a store
+type shell struct {
+	store  *Store
+	runner *commandRunner
+	opts   ShellOptions
+	out    io.Writer
+	// How much of the runner's log has been written to the audit log
+	logged int
+}
+
+// Read and run commands from in until it runs out, or 'quit'. Output,
+// including what the commands do, goes to out. If in is a terminal,
+// a prompt is shown and words complete with tab; otherwise the lines
+// are simply read, so the shell can be scripted. A line's commands are
+// kept only if every one of them works.
+func (st *Store) Shell(in io.Reader, out io.Writer, opts ShellOptions) (err error) {
+	sh := &shell{store: st, opts: opts, out: out}
+
+	st.mu.Lock()
+	sh.runner = newCommandRunner(st.s)
+	sh.runner.actAs(opts.Principal, true)
+	st.mu.Unlock()
+
+	reader := bufio.NewReader(in)
+	next := func() (string, error) {
+		line, err := reader.ReadString('\n')
+		if err == io.EOF && line != "" {
+			err = nil
+		}
+		return strings.TrimRight(line, "\r\n"), err
+	}
+
+	if f, ok := in.(*os.File); ok && isTerminal(f) {
+		restore, err := makeRaw(f)
+		if err != nil {
+			return err
+		}
+		defer restore()
+
+		fmt.Fprintf(out, "Type 'help' for the commands, and tab to complete words.\r\n")
+		next = func() (string, error) {
+			return readLine(reader, out, "acl> ", sh.complete)
+		}
+	}
+
+	for num := 1; ; num++ {
+		line, err := next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if !sh.do(num, line) {
+			return nil
+		}
+	}
+}
+
+// Run one line, returning false to quit
+func (sh *shell) do(num int, line string) bool {
+	lines := lex("shell", line)
+	tokens := lines[0]
+	for idx := range tokens {
+		tokens[idx].pos.line = num
+	}
+	if len(tokens) == 0 {
+		return true
+	}
+
+	verb, args := tokens[0].text, tokens[1:]
+	switch verb {
+	case "quit", "exit":
+		return false
+	case "help":
+		sh.help(args)
+		return true
+	case "show":
+		sh.show(args)
+		return true
+	case "save":
+		sh.save(args)
+		return true
+	case "check":
+		tokens[0].text = "ck"
+	case "undo", "redo":
+		// Just one, unless it says otherwise
+		if len(args) == 0 {
+			tokens = append(tokens, token{tokens[0].pos, "1"})
+		}
+	}
+
+	cmds, diags := parseCommands(tokens)
+	for _, d := range diags {
+		fmt.Fprintf(sh.out, "%v\n", d)
+	}
+
+	sh.store.mu.Lock()
+	defer sh.store.mu.Unlock()
+
+	// The line is run on a copy of the lists, which only replaces them
+	// if every command on it worked, as Store.run does. The copy is of
+	// whatever the lists are now, even if somebody else (say, a Grant)
+	// replaced them since the last line.
+	runner := *sh.runner
+	runner.store = sh.store.s.clone()
+	runner.store.msgs = sh.out
+	if sh.runner.acl != nil {
+		runner.acl, _ = runner.store.lookup(sh.runner.acl.filename)
+	}
+	runner.done = append([]change(nil), sh.runner.done...)
+	runner.undone = append([]change(nil), sh.runner.undone...)
+	runner.log = append([]auditEvent(nil), sh.runner.log...)
+
+	failed := len(diags) > 0
+	for _, cmd := range cmds {
+		runner.diags = nil
+		runner.run(cmd)
+		for _, d := range runner.diags {
+			fmt.Fprintf(sh.out, "%v\n", d)
+		}
+		failed = failed || len(runner.diags) > 0
+	}
+
+	if failed {
+		fmt.Fprintf(sh.out, "Line had problems, no changes were made\n")
+		return true
+	}
+	runner.store.msgs = sh.store.s.msgs
+	sh.store.s = runner.store
+	*sh.runner = runner
+
+	return true
+}
+
+func (sh *shell) help(args []token) {
+	if len(args) > 0 {
+		if text, ok := commandHelp[args[0].text]; ok {
+			fmt.Fprintf(sh.out, "%s\n", text)
+		} else if text, ok := shellHelp[args[0].text]; ok {
+			fmt.Fprintf(sh.out, "%s\n", text)
+		} else {
+			fmt.Fprintf(sh.out, "%s is not a command\n", args[0].text)
+		}
+		return
+	}
+
+	fmt.Fprintf(sh.out, "Commands, one or more to a line:\n")
+	for _, verb := range shellVerbs() {
+		text := commandHelp[verb]
+		if text == "" {
+			text = shellHelp[verb]
+		}
+		fmt.Fprintf(sh.out, "  %s\n", text)
+	}
+	fmt.Fprintf(sh.out, "Rights are numbers (6), letters (rw) or names (read,write).\n")
+}
+
+func (sh *shell) show(args []token) {
+	sh.store.mu.RLock()
+	defer sh.store.mu.RUnlock()
+
+	switch {
+	case len(args) > 0 && args[0].text == "all":
+		fmt.Fprintf(sh.out, "%v", sh.store.s)
+	case len(args) > 0:
+		acl, ok := sh.store.s.resolve(args[0].text)
+		if !ok {
+			fmt.Fprintf(sh.out, "%v\n", diagnostic{args[0].pos, D_UNKNOWN_FILE, args[0].text,
+				"no access control list applies to file"})
+			return
+		}
+		fmt.Fprintf(sh.out, "%v", acl)
+	case sh.runner.acl != nil:
+		fmt.Fprintf(sh.out, "%v", sh.runner.acl)
+	default:
+		fmt.Fprintf(sh.out, "%v", sh.store.s)
+	}
+}
+
+func (sh *shell) save(args []token) {
+	filename := sh.opts.SaveTo
+	if len(args) > 0 {
+		filename = args[0].text
+	}
+	if filename == "" {
+		fmt.Fprintf(sh.out, "Nowhere to save to, say 'save <file>'\n")
+		return
+	}
+
+	if err := sh.store.WriteFile(filename, sh.opts.SaveFormat); err != nil {
+		fmt.Fprintf(sh.out, "%v\n", err)
+		return
+	}
+	fmt.Fprintf(sh.out, "Access control lists written to %s\n", filename)
+
+	if sh.opts.Key != nil {
+		if _, err := SignFile(filename, sh.opts.SaveFormat, sh.opts.Key); err != nil {
+			fmt.Fprintf(sh.out, "%v\n", err)
+			return
+		}
+		fmt.Fprintf(sh.out, "Signature written to %s%s\n", filename, SigSuffix)
+	}
+
+	if sh.opts.AuditLog != "" {
+		sh.store.mu.RLock()
+		events := sh.runner.log[sh.logged:]
+		sh.store.mu.RUnlock()
+
+		if err := writeAuditLog(sh.opts.AuditLog, events); err != nil {
+			fmt.Fprintf(sh.out, "%v\n", err)
+			return
+		}
+		sh.logged += len(events)
+	}
+}
+
+// Every command the shell takes, sorted
+func shellVerbs() (verbs []string) {
+	for verb := range commandArgs {
+		verbs = append(verbs, verb)
+	}
+	for verb := range shellHelp {
+		verbs = append(verbs, verb)
+	}
+	sort.Strings(verbs)
+	return
+}
+
+// The words the last word of a line could be: commands to start a
+// line, files after 'file', 'show', 'rules' and the inheritance
+// commands, roles after the user of 'assign' and 'revoke', and users
+// on the list being worked on wherever else a command takes a name
+func (sh *shell) complete(line string) (words []string) {
+	fields := strings.Fields(line)
+	current := lastWord(line)
+	idx := len(fields)
+	if current != "" {
+		idx--
+	}
+
+	sh.store.mu.RLock()
+	defer sh.store.mu.RUnlock()
+
+	var candidates []string
+	switch {
+	case idx == 0:
+		candidates = shellVerbs()
+	case fields[0] == "help" && idx == 1:
+		candidates = shellVerbs()
+	case (fields[0] == "file" || fields[0] == "show" || fields[0] == "rules" || fields[0] == "block" ||
+		fields[0] == "inherit" || fields[0] == "effective") && idx == 1:
+		candidates = sh.store.s.order
+	case (fields[0] == "assign" || fields[0] == "revoke") && idx == 2:
+		candidates = roles.names()
+	default:
+		verb := fields[0]
+		if verb == "check" {
+			verb = "ck"
+		}
+		kinds := commandArgs[verb]
+		if idx-1 < len(kinds) && kinds[idx-1] == A_NAME && sh.runner.acl != nil {
+			for _, entry := range sh.runner.acl.ace.all() {
+				candidates = append(candidates, entry.user)
+			}
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, current) && !seen[candidate] {
+			seen[candidate] = true
+			words = append(words, candidate)
+		}
+	}
+	sort.Strings(words)
+	return
+}
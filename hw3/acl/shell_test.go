@@ -0,0 +1,83 @@
+package acl
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestShell(t *testing.T) {
+	SetMessages(ioutil.Discard)
+	defer SetMessages(os.Stdout)
+
+	store := NewStore()
+	if err := store.ReadFile("../acl1.txt", F_ACL); err != nil {
+		t.Fatal(err)
+	}
+
+	saved := filepath.Join(t.TempDir(), "acl.txt")
+	script := "ar ubuntu 1\n" +
+		"check ubuntu rwx\n" +
+		"dr nobody 4\n" +
+		"ae bob r ar nobody w\n" +
+		"ae alice r\n" +
+		"undo\n" +
+		"show\n" +
+		"save\n" +
+		"quit\n" +
+		"de ubuntu\n"
+
+	var out strings.Builder
+	if err := store.Shell(strings.NewReader(script), &out, ShellOptions{SaveTo: saved, SaveFormat: F_ACL}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Problems are reported but don't stop the shell, and nothing
+	// after quit runs
+	if !strings.Contains(out.String(), `shell:3:4: unknown user`) {
+		t.Errorf("Fail: %s\n", out.String())
+	}
+	if allowed, why := store.Check("main.c", "ubuntu", R_READ|R_WRITE|R_EXEC); !allowed {
+		t.Errorf("Fail: %s\n", why)
+	}
+	if allowed, _ := store.Check("main.c", "alice", R_READ); allowed {
+		t.Errorf("Fail: alice should have been undone\n")
+	}
+	// A line with a problem changes nothing, not even what worked
+	if !strings.Contains(out.String(), "Line had problems, no changes were made") {
+		t.Errorf("Fail: %s\n", out.String())
+	}
+	if allowed, _ := store.Check("main.c", "bob", R_READ); allowed {
+		t.Errorf("Fail: bob's entry was kept\n")
+	}
+
+	reread := newACLStore()
+	if err := reread.parseFileAs(saved, F_ACL); err != nil {
+		t.Fatal(err)
+	}
+	if !reread.check("main.c", "ubuntu", R_EXEC).allowed {
+		t.Errorf("Fail: save didn't keep the change\n")
+	}
+
+	// Completion
+	sh := &shell{store: store, runner: newCommandRunner(store.s)}
+	tests := []struct {
+		line string
+		want string
+	}{
+		{"", strings.Join(shellVerbs(), " ")},
+		{"s", "save show"},
+		{"ar ", "crenshaw ec2-user ubuntu vegdahl"},
+		{"ar ve", "vegdahl"},
+		{"ar vegdahl ", ""},
+		{"file m", "main.c"},
+		{"help u", "undo"},
+	}
+	for _, test := range tests {
+		if got := strings.Join(sh.complete(test.line), " "); got != test.want {
+			t.Errorf("Fail: %q completes to %q, not %q\n", test.line, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package acl
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// An ACL file can be signed, so anyone who can write to it but doesn't
+// have the key can't change it without it being noticed. The signature
+// is an HMAC-SHA256, in hex, kept next to the file in <file>.sig.
+//
+// What's signed isn't the file's text but the lists it holds, written
+// out as JSON (see canonical): reformatting a file, or converting it
+// to another format, leaves its signature good, while changing who may
+// do what doesn't. Groups and roles from other files aren't covered.
+
+// Signatures are kept in a file named after the ACL file with this on
+// the end
+const SigSuffix = ".sig"
+
+// The key is read from this environment variable when there's no key
+// file
+const KeyEnv = "HW3_ACL_KEY"
+
+// A problem with a file's signature: it has none, or it's wrong
+type SignatureError struct {
+	Filename string
+	Problem  string
+}
+
+func (e SignatureError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Filename, e.Problem)
+}
+
+// Read the key from a file, or from $HW3_ACL_KEY if filename is "".
+// White space around the key is ignored. The key is nil if there is
+// no file and the variable isn't set.
+func ReadKey(filename string) (key []byte, err error) {
+	str := os.Getenv(KeyEnv)
+	if filename != "" {
+		data, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		str = string(data)
+	}
+
+	str = strings.TrimSpace(str)
+	if str == "" {
+		if filename != "" {
+			return nil, fmt.Errorf("key file %s is empty", filename)
+		}
+		return nil, nil
+	}
+	return []byte(str), nil
+}
+
+// The form of a store that's signed. JSON keeps everything about
+// every entry, in order, and doesn't care what the names look like.
+func (s *aclStore) canonical() (data []byte, err error) {
+	str, err := s.toJSON()
+	return []byte(str), err
+}
+
+// The signature of a store's lists, in hex
+func (s *aclStore) sign(key []byte) (sig string, err error) {
+	data, err := s.canonical()
+	if err != nil {
+		return "", err
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// Read a file on its own, with nothing printed, for signing it or
+// checking its signature
+func readAlone(filename, format string) (s *aclStore, err error) {
+	s = newACLStore()
+	s.msgs = ioutil.Discard
+	return s, s.parseFileAs(filename, format)
+}
+
+// Sign an ACL file in a format, writing the signature to <file>.sig.
+// The file has to parse.
+func SignFile(filename, format string, key []byte) (sig string, err error) {
+	s, err := readAlone(filename, format)
+	if err != nil {
+		return "", err
+	}
+
+	if sig, err = s.sign(key); err != nil {
+		return "", err
+	}
+	return sig, writeFileAtomic(filename+SigSuffix, []byte(sig+"\n"))
+}
+
+// Check a store read from a file against the file's signature
+func (s *aclStore) verify(filename string, key []byte) error {
+	data, err := ioutil.ReadFile(filename + SigSuffix)
+	if os.IsNotExist(err) {
+		return SignatureError{filename, "not signed, there's no " + filename + SigSuffix}
+	}
+	if err != nil {
+		return err
+	}
+
+	got, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return SignatureError{filename, "signature isn't hex"}
+	}
+
+	want, err := s.sign(key)
+	if err != nil {
+		return err
+	}
+	wantBytes, _ := hex.DecodeString(want)
+	if !hmac.Equal(got, wantBytes) {
+		return SignatureError{filename, "signature doesn't match, the file has been changed " +
+			"or was signed with another key"}
+	}
+	return nil
+}
+
+// Read the lists in a file into the store, as ReadFile does, but check
+// the file's signature first. The file is read once, checked, and only
+// then added to the store, so what's checked is what's kept.
+//
+// If the signature is missing or wrong, the problem is returned as a
+// SignatureError. When strict, nothing is added to the store then;
+// otherwise the file is added anyway, and it's up to the caller to
+// warn about it.
+func (st *Store) ReadSignedFile(filename, format string, key []byte, strict bool) (err error) {
+	s, err := readAlone(filename, format)
+	if err != nil {
+		return err
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	fmt.Fprintf(st.s.messages(), "%s was successfully opened.\nChecking the signature of the file.\n", filename)
+
+	problem := s.verify(filename, key)
+	if _, isSig := problem.(SignatureError); problem != nil && (strict || !isSig) {
+		return problem
+	}
+
+	if err := st.s.checkNames(s); err != nil {
+		return err
+	}
+	st.s.merge(s)
+	return problem
+}
+
+// Check the users of every entry and group member in another store
+// against this one's user directory, as reading the other's file into
+// this store would have. The groups of both count as defined.
+func (s *aclStore) checkNames(other *aclStore) error {
+	merged := s.clone()
+	merged.merge(other)
+
+	var diags diagnostics
+	for _, group := range other.groups.order {
+		for _, member := range other.groups.members[group] {
+			merged.checkKnownName(token{position{filename: groupPrefix + group}, member}, &diags)
+		}
+	}
+	for _, filename := range other.order {
+		for _, entry := range other.lists[filename].ace.all() {
+			merged.checkKnownName(token{position{filename: filename}, entry.user}, &diags)
+		}
+	}
+	return diags.err()
+}
+
+// Add the groups and lists of another store to this one. Lists this
+// store already has get the other's entries added to the end, with
+// owners moved to the front.
+func (s *aclStore) merge(other *aclStore) {
+	for _, group := range other.groups.order {
+		s.groups.addGroup(group)
+		for _, member := range other.groups.members[group] {
+			s.groups.addMember(group, member)
+		}
+	}
+
+	for _, filename := range other.order {
+		acl, fresh := s.add(filename)
+		acl.noInherit = acl.noInherit || other.lists[filename].noInherit
+		for _, entry := range other.lists[filename].ace.all() {
+			if acl.appendEntry(entry) && !fresh && entry.isOwner() {
+				acl.reorder(acl.ace.len() - 1)
+			}
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package acl
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSignatures(t *testing.T) {
+	SetMessages(ioutil.Discard)
+	defer SetMessages(os.Stdout)
+
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "acl.txt")
+	data, _ := ioutil.ReadFile("../acl1.txt")
+	ioutil.WriteFile(filename, data, 0644)
+	key := []byte("sekrit")
+
+	// Not signed yet
+	store := NewStore()
+	if err, ok := store.ReadSignedFile(filename, F_ACL, key, true).(SignatureError); !ok || len(store.Files()) != 0 {
+		t.Errorf("Fail: %v\n", err)
+	}
+
+	if _, err := SignFile(filename, F_ACL, key); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.ReadSignedFile(filename, F_ACL, key, true); err != nil || len(store.Files()) != 1 {
+		t.Errorf("Fail: %v\n", err)
+	}
+
+	// Reformatting doesn't matter, but changing rights does, as does
+	// the key
+	ioutil.WriteFile(filename, []byte(strings.Replace(string(data), "\n6", " rw", -1)), 0644)
+	if err := NewStore().ReadSignedFile(filename, F_ACL, key, true); err != nil {
+		t.Errorf("Fail: %v\n", err)
+	}
+	if err := NewStore().ReadSignedFile(filename, F_ACL, []byte("guess"), true); err == nil {
+		t.Errorf("Fail: another key worked\n")
+	}
+
+	ioutil.WriteFile(filename, []byte(strings.Replace(string(data), "\n1\n", "\n7\n", -1)), 0644)
+	store = NewStore()
+	if _, ok := store.ReadSignedFile(filename, F_ACL, key, true).(SignatureError); !ok || len(store.Files()) != 0 {
+		t.Errorf("Fail: tampered file was read\n")
+	}
+
+	// Unless not strict, when it's read anyway
+	if _, ok := store.ReadSignedFile(filename, F_ACL, key, false).(SignatureError); !ok {
+		t.Errorf("Fail: tampered file passed\n")
+	}
+	if allowed, _ := store.Check("main.c", "ec2-user", R_READ); !allowed {
+		t.Errorf("Fail: %v\n", store)
+	}
+
+	// Keys come from a file, or the environment
+	keyFile := filepath.Join(dir, "key")
+	ioutil.WriteFile(keyFile, []byte("sekrit\n"), 0600)
+	if k, err := ReadKey(keyFile); err != nil || string(k) != "sekrit" {
+		t.Errorf("Fail: %q %v\n", k, err)
+	}
+	os.Setenv(KeyEnv, "from-env")
+	defer os.Unsetenv(KeyEnv)
+	if k, err := ReadKey(""); err != nil || string(k) != "from-env" {
+		t.Errorf("Fail: %q %v\n", k, err)
+	}
+}
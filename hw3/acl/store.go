@@ -0,0 +1,122 @@
+package acl
+
+import (
+	"fmt"
+	"io"
+)
+
+// An aclStore holds one access control list per file, keyed by
+// filename. The order the files were added in is remembered so
+// printing is stable (maps have no order in Go). Groups are kept
+// here too, since every list in the store shares them.
+type aclStore struct {
+	lists  map[string]*accessControlList
+	order  []string
+	groups *groupTable
+	// Most owners a list may have, 0 for no limit. See owners.go
+	maxOwners int
+	// The users and groups names are checked against, if any, and
+	// whether unknown ones are rejected or just warned about. See
+	// directory.go
+	directory     *userDirectory
+	rejectUnknown bool
+	// Where messages about the store go, nil for wherever SetMessages
+	// says
+	msgs io.Writer
+}
+
+// Make a new, empty store
+func newACLStore() *aclStore {
+	return &aclStore{
+		lists:  make(map[string]*accessControlList),
+		order:  make([]string, 0),
+		groups: newGroupTable(),
+	}
+}
+
+// Add a new, empty ACL for filename to the store and return it.
+// If the file already has an ACL, the existing one is returned
+// and ok is false.
+func (s *aclStore) add(filename string) (acl *accessControlList, ok bool) {
+	if acl, found := s.lists[filename]; found {
+		return acl, false
+	}
+
+	acl = new(accessControlList)
+	acl.initialize(filename)
+	acl.groups = s.groups
+
+	s.lists[filename] = acl
+	s.order = append(s.order, filename)
+
+	return acl, true
+}
+
+// Make a copy of the store that can be changed without changing this one
+func (s *aclStore) clone() *aclStore {
+	c := newACLStore()
+	c.maxOwners = s.maxOwners
+	c.directory, c.rejectUnknown = s.directory, s.rejectUnknown
+	c.msgs = s.msgs
+
+	for _, group := range s.groups.order {
+		c.groups.addGroup(group)
+		c.groups.members[group] = append([]string(nil), s.groups.members[group]...)
+	}
+
+	for _, filename := range s.order {
+		acl, _ := c.add(filename)
+		acl.ace = entryListOf(s.lists[filename].ace.all())
+		acl.noInherit = s.lists[filename].noInherit
+	}
+
+	return c
+}
+
+// Where the store's messages go
+func (s *aclStore) messages() io.Writer {
+	if s.msgs != nil {
+		return s.msgs
+	}
+	return messages()
+}
+
+// Look up the ACL for a file.
+// ok is false if the file has no ACL in the store.
+func (s *aclStore) lookup(filename string) (acl *accessControlList, ok bool) {
+	acl, ok = s.lists[filename]
+	return
+}
+
+// The ACL a command file works on when it doesn't name one with
+// a 'file' directive: the first one added. nil if the store is empty.
+func (s *aclStore) first() *accessControlList {
+	if len(s.order) == 0 {
+		return nil
+	}
+	return s.lists[s.order[0]]
+}
+
+// Number of ACLs in the store
+func (s *aclStore) len() int {
+	return len(s.order)
+}
+
+// Stringify every ACL in the store, in the order they were added
+func (s *aclStore) String() (str string) {
+	// An empty store prints like an empty list always has
+	if len(s.order) == 0 {
+		return fmt.Sprintf("%v", new(accessControlList))
+	}
+
+	for _, filename := range s.order {
+		str += fmt.Sprintf("%v", s.lists[filename])
+	}
+
+	// Only bother with groups if there are any
+	if len(s.groups.order) > 0 {
+		str += fmt.Sprintf("%v", s.groups)
+	}
+
+	return
+}
@@ -0,0 +1,34 @@
+package acl
+
+import (
+	"testing"
+)
+
+// The store the tests read files into, as the command used to
+var acls *aclStore
+
+func TestMultiFileStore(t *testing.T) {
+	acls = newACLStore()
+
+	if err := acls.parseInputFile("../acl4.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	if acls.len() != 3 {
+		t.Fatalf("Fail: %d lists, wanted 3\n", acls.len())
+	}
+
+	if err := acls.parseCommandFile("../commands5.txt", "", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	grade, ok := acls.lookup("grade.sh")
+	if !ok || grade.ace.len() != 1 || grade.ace.at(0).rights != R_OWN|R_READ|R_EXEC {
+		t.Errorf("Fail: %v\n", grade)
+	}
+
+	mainC, _ := acls.lookup("main.c")
+	if mainC.ace.at(1).user != "vegdahl" || mainC.ace.at(1).rights != R_WRITE {
+		t.Errorf("Fail: %v\n", mainC)
+	}
+}
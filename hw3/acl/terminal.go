@@ -0,0 +1,121 @@
+package acl
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Just enough of a line editor for the shell to complete words with
+// tab: typing, backspace, tab, enter, ^C to throw the line away and
+// ^D on an empty line to quit. Anything fancier (arrow keys, history)
+// is ignored.
+//
+// Putting the terminal in raw mode is up to the system, see
+// terminal_linux.go. Elsewhere the shell reads lines as they come,
+// without completion.
+
+// Keys the line editor knows about
+const (
+	K_CTRL_C    = 3
+	K_CTRL_D    = 4
+	K_BACKSPACE = 8
+	K_TAB       = '\t'
+	K_ESCAPE    = 27
+	K_DELETE    = 127
+)
+
+// Read a line from a terminal in raw mode, echoing it to out.
+// complete gives the words the last word of the line could be.
+func readLine(in *bufio.Reader, out io.Writer, prompt string, complete func(line string) []string) (line string, err error) {
+	var buf []rune
+	fmt.Fprint(out, prompt)
+
+	for {
+		r, _, err := in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+
+		switch r {
+		case '\r', '\n':
+			fmt.Fprint(out, "\r\n")
+			return string(buf), nil
+
+		case K_CTRL_D:
+			if len(buf) == 0 {
+				fmt.Fprint(out, "\r\n")
+				return "", io.EOF
+			}
+
+		case K_CTRL_C:
+			buf = buf[:0]
+			fmt.Fprintf(out, "^C\r\n%s", prompt)
+
+		case K_BACKSPACE, K_DELETE:
+			if len(buf) > 0 {
+				buf = buf[:len(buf)-1]
+				fmt.Fprint(out, "\b \b")
+			}
+
+		case K_ESCAPE:
+			// Arrow keys and the like are escape sequences, like
+			// '\x1b[A'. Skip them.
+			if next, _, err := in.ReadRune(); err == nil && next == '[' {
+				in.ReadRune()
+			}
+
+		case K_TAB:
+			current := lastWord(string(buf))
+			words := complete(string(buf))
+			prefix := commonPrefix(words)
+
+			switch {
+			case len(words) == 0:
+				fmt.Fprint(out, "\a")
+			case len(words) == 1:
+				rest := []rune(strings.TrimPrefix(words[0], current) + " ")
+				buf = append(buf, rest...)
+				fmt.Fprint(out, string(rest))
+			case len(prefix) > len(current):
+				rest := []rune(strings.TrimPrefix(prefix, current))
+				buf = append(buf, rest...)
+				fmt.Fprint(out, string(rest))
+			default:
+				fmt.Fprintf(out, "\r\n%s\r\n%s%s", strings.Join(words, "  "), prompt, string(buf))
+			}
+
+		default:
+			if r >= ' ' {
+				buf = append(buf, r)
+				fmt.Fprint(out, string(r))
+			}
+		}
+	}
+}
+
+// The word being typed at the end of a line, "" if a new one is
+// about to start
+func lastWord(line string) string {
+	if line == "" || strings.HasSuffix(line, " ") {
+		return ""
+	}
+	words := strings.Fields(line)
+	return words[len(words)-1]
+}
+
+// The longest prefix every word shares
+func commonPrefix(words []string) (prefix string) {
+	if len(words) == 0 {
+		return ""
+	}
+
+	prefix = words[0]
+	for _, word := range words[1:] {
+		for !strings.HasPrefix(word, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return
+}
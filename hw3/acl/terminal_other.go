@@ -0,0 +1,18 @@
+//go:build !linux
+
+package acl
+
+import (
+	"errors"
+	"os"
+)
+
+// Raw mode is only done on Linux, so nothing else counts as a
+// terminal, and the shell reads plain lines
+func isTerminal(f *os.File) bool {
+	return false
+}
+
+func makeRaw(f *os.File) (restore func(), err error) {
+	return nil, errors.New("raw mode isn't supported on this system")
+}
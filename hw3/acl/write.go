@@ -0,0 +1,139 @@
+package acl
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// Format of ACL files, as read by parseInputFile. Also accepted as
+// F_TEXT when reading, since that's what it has always been called.
+const F_ACL = "acl"
+
+// Names have to survive being split into words again
+func checkName(kind, name string) error {
+	if name == "" || strings.IndexFunc(name, unicode.IsSpace) >= 0 {
+		return fmt.Errorf("%s %q can't be written to an ACL file", kind, name)
+	}
+	return nil
+}
+
+// Stringify a store in the format parseInputFile reads. Parsing the
+// result gives back the same store: group definitions come first,
+// then each list with its entries in order, except that owners are
+// written last-to-first, since addEntry puts each new owner in front
+// of the ones before it.
+func (s *aclStore) toACLFile() (str string, err error) {
+	for _, group := range s.groups.order {
+		if err := checkName("group", group); err != nil {
+			return "", err
+		}
+
+		str += fmt.Sprintf("g: %s", group)
+		for _, member := range s.groups.members[group] {
+			if err := checkName("user", member); err != nil {
+				return "", err
+			}
+			str += " " + member
+		}
+		str += "\n"
+	}
+
+	for _, filename := range s.order {
+		acl := s.lists[filename]
+		if err := checkName("file", filename); err != nil {
+			return "", err
+		}
+
+		if acl.noInherit {
+			str += fmt.Sprintf(": %s %s\n", filename, noInherit)
+		} else {
+			str += fmt.Sprintf(": %s\n", filename)
+		}
+
+		// The owners, at the front of the list
+		all := acl.ace.all()
+		owners := 0
+		for owners < len(all) && all[owners].isOwner() {
+			owners++
+		}
+
+		entries := make([]accessControlEntry, 0, len(all))
+		for idx := owners - 1; idx >= 0; idx-- {
+			entries = append(entries, all[idx])
+		}
+		entries = append(entries, all[owners:]...)
+
+		for _, entry := range entries {
+			if err := checkName("user", entry.user); err != nil {
+				return "", err
+			}
+
+			marker := "*"
+			if entry.deny {
+				marker = "-"
+			}
+			str += fmt.Sprintf("%s %s\n%d%s", marker, entry.user, entry.rights, entry.roleNames())
+			if !entry.notBefore.IsZero() {
+				str += " from " + formatTimeLimit(entry.notBefore)
+			}
+			if !entry.expires.IsZero() {
+				str += " until " + formatTimeLimit(entry.expires)
+			}
+			str += "\n"
+		}
+	}
+
+	return str, nil
+}
+
+// Write data to a file all at once: it goes to a temporary file in
+// the same directory first, which then replaces the real one. Anyone
+// reading the file sees either the old contents or the new, never
+// half of the new.
+func writeFileAtomic(filename string, data []byte) (err error) {
+	dir, base := filepath.Split(filename)
+	if dir == "" {
+		dir = "."
+	}
+
+	tmp, err := ioutil.TempFile(dir, "."+base+".")
+	if err != nil {
+		return err
+	}
+
+	// Clean up the temporary file if anything goes wrong
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	if _, err = tmp.Write(data); err != nil {
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		return err
+	}
+
+	// Keep the permissions of the file being replaced. A new file gets
+	// 0644, like one ioutil.WriteFile makes, rather than the temporary
+	// file's 0600.
+	mode := os.FileMode(0644)
+	if info, statErr := os.Stat(filename); statErr == nil {
+		mode = info.Mode()
+	}
+	if err = tmp.Chmod(mode); err != nil {
+		return err
+	}
+
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), filename)
+}
@@ -0,0 +1,56 @@
+package acl
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestACLFileRoundTrip(t *testing.T) {
+	acls = newACLStore()
+
+	if err := acls.parseInputFile("../acl4.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Shuffle the owners about a bit
+	mainC, _ := acls.lookup("main.c")
+	mainC.addEntry("root", R_ALL)
+	mainC.addRight(R_OWN, "vegdahl")
+	mainC.deleteRight(R_OWN, "root")
+	mainC.addDenyEntry("mallory", R_WRITE)
+	if mainC.ace.at(0).user != "vegdahl" || mainC.ace.at(2).user != "root" {
+		t.Errorf("Fail: %v\n", mainC)
+	}
+	want := acls.String()
+
+	str, err := acls.toACLFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	filename := filepath.Join(t.TempDir(), "acl.txt")
+	if err := writeFileAtomic(filename, []byte(str)); err != nil {
+		t.Fatal(err)
+	}
+
+	acls = newACLStore()
+	if err := acls.parseInputFile(filename); err != nil {
+		t.Fatal(err)
+	}
+	if got := acls.String(); got != want {
+		t.Errorf("Fail: %s\n", got)
+	}
+
+	// New files can be read by anyone, replaced ones keep their mode
+	if info, err := os.Stat(filename); err != nil || info.Mode().Perm() != 0644 {
+		t.Errorf("Fail: %v %v\n", info.Mode(), err)
+	}
+	os.Chmod(filename, 0600)
+	if err := writeFileAtomic(filename, []byte(str)); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(filename); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Fail: %v %v\n", info.Mode(), err)
+	}
+}
@@ -0,0 +1,342 @@
+package acl
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+// There's no YAML package in the standard library, so this file has
+// just enough YAML to write a store out and read it back in: block
+// mappings and sequences, plain, quoted and simple flow scalars, and
+// comments. Anchors, tags, multi-line scalars and the like aren't
+// supported.
+
+// Stringify a store as YAML. Strings are always double quoted, which
+// keeps names like '@ops' from meaning something else to YAML.
+func (s *aclStore) toYAML() (str string) {
+	es := s.export()
+
+	str = "files:\n"
+	if len(es.Files) == 0 {
+		str = "files: []\n"
+	}
+
+	for _, el := range es.Files {
+		str += fmt.Sprintf("  - filename: %s\n", strconv.Quote(el.Filename))
+		if el.NoInherit {
+			str += "    no_inherit: true\n"
+		}
+
+		if len(el.Entries) == 0 {
+			str += "    entries: []\n"
+			continue
+		}
+
+		str += "    entries:\n"
+		for _, ee := range el.Entries {
+			str += fmt.Sprintf("      - user: %s\n", strconv.Quote(ee.User))
+			str += fmt.Sprintf("        rights: %d\n", ee.Rights)
+			str += fmt.Sprintf("        letters: %s\n", strconv.Quote(ee.Letters))
+			if ee.Deny {
+				str += "        deny: true\n"
+			}
+			if ee.NotBefore != "" {
+				str += fmt.Sprintf("        not_before: %s\n", strconv.Quote(ee.NotBefore))
+			}
+			if ee.Expires != "" {
+				str += fmt.Sprintf("        expires: %s\n", strconv.Quote(ee.Expires))
+			}
+			if len(ee.Roles) > 0 {
+				str += "        roles:\n"
+				for _, role := range ee.Roles {
+					str += fmt.Sprintf("          - %s\n", strconv.Quote(role))
+				}
+			}
+		}
+	}
+
+	if len(es.Groups) == 0 {
+		return
+	}
+
+	str += "groups:\n"
+	for _, group := range es.Groups {
+		str += fmt.Sprintf("  - name: %s\n", strconv.Quote(group.Name))
+
+		if len(group.Members) == 0 {
+			str += "    members: []\n"
+			continue
+		}
+
+		str += "    members:\n"
+		for _, member := range group.Members {
+			str += fmt.Sprintf("      - %s\n", strconv.Quote(member))
+		}
+	}
+
+	return
+}
+
+// One line of YAML with its indentation worked out
+type yamlLine struct {
+	num    int
+	indent int
+	text   string
+}
+
+// Read a YAML file into the store. The YAML is turned into plain Go
+// values, and from there goes through the same path JSON does.
+func (s *aclStore) parseYAMLFile(filename string) (err error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(s.messages(), "%s was successfully opened.\nParsing YAML from file.\n", filename)
+
+	value, err := parseYAML(string(data))
+	if err != nil {
+		return fmt.Errorf("%s:%v", filename, err)
+	}
+
+	// Round trip through JSON to fill in the export types
+	jsonData, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	var es exportStore
+	if err := json.Unmarshal(jsonData, &es); err != nil {
+		return fmt.Errorf("%s: %v", filename, err)
+	}
+
+	return s.load(es)
+}
+
+// Parse YAML text into maps, slices and scalars
+func parseYAML(text string) (value interface{}, err error) {
+	lines := make([]yamlLine, 0)
+
+	for idx, raw := range strings.Split(text, "\n") {
+		raw = strings.TrimRight(stripYAMLComment(raw), " \r")
+		trimmed := strings.TrimLeft(raw, " ")
+
+		if trimmed == "" || trimmed == "---" || trimmed == "..." {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "\t") {
+			return nil, fmt.Errorf("%d: tabs can't be used to indent", idx+1)
+		}
+
+		lines = append(lines, yamlLine{idx + 1, len(raw) - len(trimmed), trimmed})
+	}
+
+	if len(lines) == 0 {
+		return nil, nil
+	}
+
+	value, next, err := parseYAMLNode(lines, 0, lines[0].indent)
+	if err == nil && next < len(lines) {
+		err = fmt.Errorf("%d: unexpected indentation", lines[next].num)
+	}
+
+	return
+}
+
+// Parse the block starting at lines[idx], which is indented by indent.
+// Returns the value and the index of the first line after the block.
+func parseYAMLNode(lines []yamlLine, idx, indent int) (value interface{}, next int, err error) {
+	if isYAMLSeqItem(lines[idx].text) {
+		return parseYAMLSeq(lines, idx, indent)
+	}
+	return parseYAMLMap(lines, idx, indent)
+}
+
+// Is the line an item of a sequence?
+func isYAMLSeqItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+func parseYAMLSeq(lines []yamlLine, idx, indent int) (value interface{}, next int, err error) {
+	seq := make([]interface{}, 0)
+
+	for idx < len(lines) && lines[idx].indent == indent && isYAMLSeqItem(lines[idx].text) {
+		rest := strings.TrimLeft(strings.TrimPrefix(lines[idx].text, "-"), " ")
+
+		var item interface{}
+		switch {
+		case rest == "":
+			// The item is the block on the following lines
+			idx++
+			if idx < len(lines) && lines[idx].indent > indent {
+				item, idx, err = parseYAMLNode(lines, idx, lines[idx].indent)
+			}
+
+		case isYAMLKey(rest) || isYAMLSeqItem(rest):
+			// A block starting on the same line as the dash, e.g.
+			// '- user: root'. Pretend it started on its own line.
+			lines[idx].indent += len(lines[idx].text) - len(rest)
+			lines[idx].text = rest
+			item, idx, err = parseYAMLNode(lines, idx, lines[idx].indent)
+
+		default:
+			item, err = parseYAMLScalar(rest, lines[idx].num)
+			idx++
+		}
+
+		if err != nil {
+			return nil, idx, err
+		}
+		seq = append(seq, item)
+	}
+
+	return seq, idx, nil
+}
+
+func parseYAMLMap(lines []yamlLine, idx, indent int) (value interface{}, next int, err error) {
+	m := make(map[string]interface{})
+
+	for idx < len(lines) && lines[idx].indent == indent {
+		line := lines[idx]
+
+		key, rest, ok := splitYAMLKey(line.text)
+		if !ok {
+			return nil, idx, fmt.Errorf("%d: expected 'key: value'", line.num)
+		}
+		idx++
+
+		var item interface{}
+		switch {
+		case rest != "":
+			item, err = parseYAMLScalar(rest, line.num)
+
+		case idx < len(lines) && lines[idx].indent > indent:
+			item, idx, err = parseYAMLNode(lines, idx, lines[idx].indent)
+
+		case idx < len(lines) && lines[idx].indent == indent && isYAMLSeqItem(lines[idx].text):
+			// Sequences may sit at the same indentation as their key
+			item, idx, err = parseYAMLSeq(lines, idx, indent)
+		}
+
+		if err != nil {
+			return nil, idx, err
+		}
+		m[key] = item
+	}
+
+	if idx < len(lines) && lines[idx].indent > indent {
+		return nil, idx, fmt.Errorf("%d: unexpected indentation", lines[idx].num)
+	}
+
+	return m, idx, nil
+}
+
+// Does the text start with a mapping key?
+func isYAMLKey(text string) bool {
+	_, _, ok := splitYAMLKey(text)
+	return ok
+}
+
+// Split 'key: value' into its key and value, where the key may be quoted
+func splitYAMLKey(text string) (key, rest string, ok bool) {
+	end := 0
+	if strings.HasPrefix(text, "\"") || strings.HasPrefix(text, "'") {
+		closing := strings.Index(text[1:], text[:1])
+		if closing < 0 {
+			return "", "", false
+		}
+		end = closing + 2
+	}
+
+	colon := strings.Index(text[end:], ":")
+	for colon >= 0 {
+		at := end + colon
+		if at+1 == len(text) || text[at+1] == ' ' {
+			key, err := parseYAMLScalar(text[:at], 0)
+			if err != nil {
+				return "", "", false
+			}
+			return fmt.Sprint(key), strings.TrimSpace(text[at+1:]), true
+		}
+
+		next := strings.Index(text[at+1:], ":")
+		if next < 0 {
+			break
+		}
+		colon += next + 1
+	}
+
+	return "", "", false
+}
+
+// Parse a single value, which may be a quoted string, a simple flow
+// sequence like '[a, b]', a number, a bool, null, or a plain string
+func parseYAMLScalar(text string, num int) (value interface{}, err error) {
+	text = strings.TrimSpace(text)
+
+	switch {
+	case strings.HasPrefix(text, "\""):
+		str, err := strconv.Unquote(text)
+		if err != nil {
+			return nil, fmt.Errorf("%d: bad quoted string %s", num, text)
+		}
+		return str, nil
+
+	case strings.HasPrefix(text, "'"):
+		if len(text) < 2 || !strings.HasSuffix(text, "'") {
+			return nil, fmt.Errorf("%d: bad quoted string %s", num, text)
+		}
+		return strings.Replace(text[1:len(text)-1], "''", "'", -1), nil
+
+	case strings.HasPrefix(text, "[") && strings.HasSuffix(text, "]"):
+		seq := make([]interface{}, 0)
+		inner := strings.TrimSpace(text[1 : len(text)-1])
+		if inner == "" {
+			return seq, nil
+		}
+		for _, part := range strings.Split(inner, ",") {
+			item, err := parseYAMLScalar(part, num)
+			if err != nil {
+				return nil, err
+			}
+			seq = append(seq, item)
+		}
+		return seq, nil
+
+	case text == "{}":
+		return make(map[string]interface{}), nil
+
+	case text == "true" || text == "false":
+		return text == "true", nil
+
+	case text == "null" || text == "~" || text == "":
+		return nil, nil
+	}
+
+	if n, err := strconv.ParseInt(text, 10, 64); err == nil {
+		return n, nil
+	}
+
+	return text, nil
+}
+
+// Cut a comment off the end of a line, leaving '#'s inside of
+// quotes alone
+func stripYAMLComment(line string) string {
+	var quote rune
+	for idx, r := range line {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '#' && (idx == 0 || line[idx-1] == ' '):
+			return line[:idx]
+		}
+	}
+	return line
+}
@@ -0,0 +1,3 @@
+module hw3
+
+go 1.22
@@ -1,225 +1,547 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"net/http"
 	"os"
+
+	"hw3/acl"
 )
 
-var acl accessControlList
+// Every ACL read from the input file, by filename
+var acls = acl.NewStore()
+
+// Where messages about what's going on are printed. When the ACLs
+// are printed as JSON or YAML they go to stderr instead, so stdout
+// holds nothing but the ACLs.
+var msgs io.Writer = os.Stdout
+
+// The key ACL files are signed with, nil if there's none
+var key []byte
+
+// flags
+var (
+	groupFlag     = flag.String("g", "", "File of group definitions")
+	rightsFlag    = flag.String("rights", "", "File of rights to define beyond own, read, write and exec")
+	rolesFlag     = flag.String("roles", "", "File of roles, named sets of rights entries can hold")
+	inFormatFlag  = flag.String("if", acl.F_ACL, "Format of the ACL file: acl, json, yaml, facl (getfacl text), or dir to read a directory tree's modes")
+	outFormatFlag = flag.String("of", acl.F_TEXT, "Format to print ACLs in: text, acl, json, yaml or facl (getfacl text)")
+	outFileFlag   = flag.String("o", "", "File to write the resulting ACLs to")
+	asFlag        = flag.String("as", "", "User to run commands as; only owners may change a list")
+	maxOwnersFlag = flag.Int("max-owners", 0, "Most owners a list may have, 0 for no limit")
+	auditFlag     = flag.String("audit", "", "Log file to append every change made to")
+	diffFlag      = flag.Bool("diff", false, "Compare two ACL files instead of running commands")
+	diffCmdsFlag  = flag.Bool("diff-cmds", false, "With -diff, print a command file that turns the first ACL file into the second")
+	dryRunFlag    = flag.Bool("dry-run", false, "Print the changes the command file would make, but don't make them")
+	inPlaceFlag   = flag.Bool("i", false, "Write the resulting ACLs back over the ACL file")
+	applyFlag     = flag.String("apply", "", "Directory tree to set the mode bits of from the resulting ACLs")
+	serveFlag     = flag.String("serve", "", "Address to serve the ACLs over HTTP on, e.g. ':8080', instead of running commands")
+	nowFlag       = flag.String("now", "", "Time to check and purge entries as of, instead of the current time")
+	lintFlag      = flag.Bool("lint", false, "Look over ACL files for likely mistakes instead of running commands; exits 1 if there are errors")
+	lintRulesFlag = flag.String("lint-rules", "", "With -lint, file of rule severities to use instead of the defaults")
+	keyFlag       = flag.String("key", "", "File holding the key ACL files are signed with; without it, $"+acl.KeyEnv+" is used")
+	verifyFlag    = flag.String("verify", "", "What to do about an ACL file whose signature is missing or wrong: off, warn, or strict to refuse it (the default when there's a key)")
+	signFlag      = flag.Bool("sign", false, "Sign the ACL file with the key, writing the signature to <aclFile>"+acl.SigSuffix)
+	shellFlag     = flag.Bool("shell", false, "Read commands from stdin and run each line as it comes, instead of from a command file")
+	matrixFlag    = flag.Bool("matrix", false, "Print what every user can do to every file in the ACL files, as a table or, with -of csv, as CSV")
+	filesForFlag  = flag.String("files-for", "", "Print the files in the ACL files that a user has the -right rights on")
+	usersOnFlag   = flag.String("users-on", "", "Print the users with the -right rights on a file, going by the ACL files")
+	rightFlag     = flag.String("right", "", "With -files-for or -users-on, the rights to look for; any right at all if not given")
+	passwdFlag    = flag.String("passwd", "", "File of users, in /etc/passwd format, that entries, group members and commands have to name")
+	etcGroupFlag  = flag.String("etc-group", "", "File of groups, in /etc/group format, that group entries have to name if the ACL file doesn't define them")
+	unknownFlag   = flag.String("unknown-users", "reject", "With -passwd or -etc-group, what to do about names they don't have: reject or warn")
+)
 
 func main() {
 
 	paramsCheck()
 
-	// Parse the first file
-	if err := parseInputFile(os.Args[1]); err != nil {
-		fmt.Println(err)
-		fmt.Printf("File parsing failed. Exiting program. \n")
-		// Go is garbaged collected
-		os.Exit(2)
+	// Only text output gets the running commentary
+	if *outFormatFlag != acl.F_TEXT {
+		setMessages(os.Stderr)
+	}
+
+	// Rights come before anything that might use them
+	if *rightsFlag != "" {
+		if err := acl.ReadRightsFile(*rightsFlag); err != nil {
+			fmt.Println(err)
+			fmt.Printf("Rights parsing failed. Exiting program. \n")
+			os.Exit(2)
+		}
+	}
+
+	// Then roles, which are made of rights
+	if *rolesFlag != "" {
+		if err := acl.ReadRolesFile(*rolesFlag); err != nil {
+			fmt.Println(err)
+			fmt.Printf("Roles parsing failed. Exiting program. \n")
+			os.Exit(2)
+		}
+	}
+
+	// The user directory names are checked against, if there is one.
+	// Read before the groups, so their members are checked too
+	if *passwdFlag != "" || *etcGroupFlag != "" {
+		if err := acls.ReadUserDirectory(*passwdFlag, *etcGroupFlag); err != nil {
+			fmt.Println(err)
+			fmt.Printf("User directory parsing failed. Exiting program. \n")
+			os.Exit(2)
+		}
+		acls.SetRejectUnknown(*unknownFlag == "reject")
+	}
+
+	// Groups come first, so they exist before any list uses them
+	if *groupFlag != "" {
+		if err := acls.ReadGroupFile(*groupFlag); err != nil {
+			fmt.Println(err)
+			fmt.Printf("Group parsing failed. Exiting program. \n")
+			os.Exit(2)
+		}
+	}
+
+	acls.SetMaxOwners(*maxOwnersFlag)
+
+	// So is linting
+	if *lintFlag {
+		lintFiles(flag.Args())
+		return
+	}
+
+	// Reports go over every ACL file given
+	if reporting() {
+		report(flag.Args())
+		return
+	}
+
+	// Diffing is a different thing altogether
+	if *diffFlag {
+		diffFiles(flag.Arg(0), flag.Arg(1))
+		return
+	}
+
+	// Signing doesn't need anything else
+	if *signFlag {
+		sig, err := acl.SignFile(flag.Arg(0), *inFormatFlag, key)
+		if err != nil {
+			fmt.Println(err)
+			fmt.Printf("Signing failed. Exiting program. \n")
+			os.Exit(2)
+		}
+		fmt.Printf("%s\n", sig)
+		fmt.Fprintf(msgs, "Signature written to %s%s\n", flag.Arg(0), acl.SigSuffix)
+		return
+	}
+
+	// Parse the first file, reporting every problem in it
+	readFile(flag.Arg(0))
+
+	// Serving takes over from here
+	if *serveFlag != "" {
+		fmt.Fprintf(msgs, "Serving access control lists on %s\n", *serveFlag)
+		if err := http.ListenAndServe(*serveFlag, acl.NewHandler(acls)); err != nil {
+			fmt.Println(err)
+			os.Exit(2)
+		}
+		return
+	}
+
+	// So does the shell. 'save' writes to -o, or back over the ACL
+	// file.
+	if *shellFlag {
+		filename := *outFileFlag
+		if filename == "" {
+			filename = flag.Arg(0)
+		}
+
+		opts := acl.ShellOptions{Principal: *asFlag, SaveTo: filename,
+			SaveFormat: saveFormat(), AuditLog: *auditFlag, Key: key}
+		if err := acls.Shell(os.Stdin, os.Stdout, opts); err != nil {
+			fmt.Println(err)
+			os.Exit(2)
+		}
+		return
 	}
 
-	// Print the inital ACL
-	fmt.Printf("%v\n", &acl)
+	// Without a command file, just print the ACLs (maybe in another
+	// format) and be done
+	if flag.NArg() == 1 {
+		printStore()
+		saveStore()
+		applyStore(acls)
+		return
+	}
+
+	// Print the inital ACLs
+	fmt.Fprintf(msgs, "%v\n", acls)
+
+	// A dry run works out what the command file would do, without
+	// doing it
+	if *dryRunFlag {
+		after, err := acls.DryRun(flag.Arg(1), *asFlag)
+		if err != nil {
+			fmt.Println(err)
+			fmt.Printf("Command file had problems. Exiting program. \n")
+			os.Exit(2)
+		}
+
+		fmt.Printf("Dry run, the command file would make these changes:\n")
+		acl.PrintChanges(acls, after)
+		applyStore(after)
+		return
+	}
 
 	// Parse the second input file, altering the access control list
-	// that was created by the first input file.
-	if err := parseCommandFile(os.Args[2]); err != nil {
-		fmt.Println(err)
-		fmt.Printf("Command parsing failed. Exiting program. \n")
-		// Go is garbaged collected
+	// that was created by the first input file. If any command fails
+	// none of them are kept.
+	cmdErr := acls.RunCommandFile(flag.Arg(1), *asFlag, *auditFlag)
+
+	// Print the resulting ACLs
+	printStore()
+
+	if cmdErr != nil {
+		fmt.Println(cmdErr)
+		fmt.Printf("Command file had problems, no changes were made. Exiting program. \n")
 		os.Exit(2)
 	}
 
-	// Print the resulting ACL
-	fmt.Printf("%v\n", &acl)
-
+	saveStore()
+	applyStore(acls)
 }
 
-// Panic helper
-func check(e error) {
-	if e != nil {
-		panic(e)
+// Load two ACL files and print what changed from the first to the
+// second, either as a report or as a command file that makes the
+// changes.
+func diffFiles(first, second string) {
+	stores := make([]*acl.Store, 0, 2)
+
+	// Keep the command file clean
+	if *diffCmdsFlag {
+		setMessages(os.Stderr)
+	}
+
+	for _, filename := range []string{first, second} {
+		acls = acl.NewStore()
+		readFile(filename)
+		stores = append(stores, acls)
+	}
+
+	if !*diffCmdsFlag {
+		fmt.Printf("Differences from %s to %s:\n", first, second)
+		acl.PrintChanges(stores[0], stores[1])
+		return
+	}
+
+	cmds, skipped := acl.DiffCommands(stores[0], stores[1])
+	fmt.Print(cmds)
+
+	for _, problem := range skipped {
+		fmt.Fprintf(os.Stderr, "%s\n", problem)
 	}
 }
 
-func paramsCheck() {
-	if len(os.Args) != 3 {
-		fmt.Printf("%s error: incorrect number of parameters.\n"+
-			"usage: %s <aclFile> <commandFile>\n", os.Args[0], os.Args[0])
+// Lint ACL files, printing what's found as text or JSON. Exits 1 if
+// any of it is an error.
+func lintFiles(filenames []string) {
+	rules := acl.DefaultLintRules()
+	if *lintRulesFlag != "" {
+		if err := rules.ReadFile(*lintRulesFlag); err != nil {
+			fmt.Println(err)
+			fmt.Printf("The lint rules are:\n%s", acl.LintRuleHelp())
+			fmt.Printf("Lint rules parsing failed. Exiting program. \n")
+			os.Exit(2)
+		}
+	}
 
+	// The findings say all there is to say
+	setMessages(ioutil.Discard)
+
+	var findings acl.LintFindings
+	for _, filename := range filenames {
+		found, err := acls.Lint(filename, rules)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(2)
+		}
+		findings = append(findings, found...)
+	}
+
+	switch *outFormatFlag {
+	case acl.F_TEXT:
+		fmt.Print(findings)
+		fmt.Printf("%d errors, %d warnings, %d notes in %d files\n", findings.Count(acl.S_ERROR),
+			findings.Count(acl.S_WARNING), findings.Count(acl.S_INFO), len(filenames))
+	case acl.F_JSON:
+		str, err := findings.JSON()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(2)
+		}
+		fmt.Print(str)
+	default:
+		fmt.Printf("%s error: -lint prints text or json, not %s.\n", os.Args[0], *outFormatFlag)
 		os.Exit(2)
 	}
+
+	if findings.Count(acl.S_ERROR) > 0 {
+		os.Exit(1)
+	}
+}
+
+// Is a report wanted instead of running commands?
+func reporting() bool {
+	return *matrixFlag || *filesForFlag != "" || *usersOnFlag != ""
 }
 
-/* parseInputFile
- *
- * Description: This function reads an input file and constructs an
- *              access control list from the contents of the file.  The
- *              expected input file format is:
- *
- *     f: <filename>
- *     user1
- *     <integer describing rights for user1>
- *     user2
- *     <integer describing rights for user2>
- *     ...
- *     userN
- *     <integer describing rights for userN>
- *
- *  The set of rights available are own, read, write, and execute and
- *  are expressed in four bits.  For example, if a user owns and may read
- *  the file, his set of rights is expressed by 0b1100 or 12.
- *
- *  Only one access control list is created as a result of this function call.
- *
- *  Usernames may not begin with a colon or an asterix.
- *
- */
-func parseInputFile(filename string) (err error) {
-
-	// attempt to open file
-	file, err := os.Open(filename)
-	// data, err := ioutil.ReadFile(filename)
+// Read every ACL file into acls and print the report asked for: the
+// access matrix, or the files a user can do something to, or the users
+// who can do something to a file.
+func report(filenames []string) {
+	r, err := acl.ParseRights(*rightFlag)
+	if *rightFlag == "" {
+		r, err = 0, nil
+	}
 	if err != nil {
-		return err
+		fmt.Printf("%s error: -right: %v.\n", os.Args[0], err)
+		os.Exit(2)
 	}
-	defer file.Close()
-	fmt.Printf("%s was successfully opened.\nParsing access control entries from file.\n", filename)
 
-	// n, err := fmt.Sscanf(string(data), "%c")
-	var a rune
-	var d right
-	var word string
+	// The report says all there is to say
+	setMessages(ioutil.Discard)
+	for _, filename := range filenames {
+		readFile(filename)
+	}
 
-	for {
-		_, err := fmt.Fscanf(file, "%c", &a)
-		switch {
-		case err == io.EOF:
-			return nil
-		case err != nil:
-			return err
+	switch {
+	case *matrixFlag && *outFormatFlag == acl.F_TEXT:
+		fmt.Print(acls.Matrix())
+	case *matrixFlag && *outFormatFlag == acl.F_CSV:
+		str, err := acls.Matrix().CSV()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(2)
 		}
-		// fmt.Printf("%d: '%c' and %v\n", n, a, err)
+		fmt.Print(str)
+	case *matrixFlag:
+		fmt.Printf("%s error: -matrix prints text or csv, not %s.\n", os.Args[0], *outFormatFlag)
+		os.Exit(2)
 
-		// If a colon is read, the next word is the file for the ACL
-		if a == ':' {
-			// Attempt to read the next word, bail if fail
-			fmt.Fscanf(file, "%s", &word)
+	case *filesForFlag != "":
+		files := acls.FilesWhere(*filesForFlag, r)
+		if len(files) == 0 {
+			fmt.Printf("No files.\n")
+		}
+		for _, file := range files {
+			fmt.Printf("%v\n", file)
+		}
 
-			// fmt.Printf("%d: '%s' and %v\n", m, word, err)
+	default:
+		users := acls.UsersWith(*usersOnFlag, r)
+		if len(users) == 0 {
+			fmt.Printf("No users.\n")
+		}
+		for _, user := range users {
+			fmt.Printf("%v\n", user)
+		}
+	}
+}
 
-			// initializeACL(word, &aclist)
-			acl.initialize(word)
-		} else if a == '*' {
-			// if there's a '*' then next word is username
-			_, err1 := fmt.Fscanf(file, "%s\n", &word)
-			if err1 != nil {
-				return err1
-			}
-			_, err2 := fmt.Fscanf(file, "%d", &d)
-			if err2 != nil {
-				return err2
-			}
+// Read an ACL file into acls, checking its signature as -verify says.
+// Bails if it can't be read, or its signature is no good and that
+// matters.
+func readFile(filename string) {
+	var err error
+	if *verifyFlag == "off" {
+		err = acls.ReadFile(filename, *inFormatFlag)
+	} else {
+		err = acls.ReadSignedFile(filename, *inFormatFlag, key, *verifyFlag == "strict")
+	}
 
-			// fmt.Printf("user: %s\nrights: %d\n", word, d)
+	if sigErr, ok := err.(acl.SignatureError); ok {
+		if *verifyFlag == "warn" {
+			fmt.Fprintf(os.Stderr, "**********\n"+
+				"WARNING: %v.\n"+
+				"Its access control lists may have been tampered with. Using them anyway.\n"+
+				"**********\n", sigErr)
+			return
+		}
 
-			acl.addEntry(word, d)
+		fmt.Println(sigErr)
+		fmt.Printf("Signature check failed, refusing the file. Exiting program. \n")
+		os.Exit(2)
+	}
 
+	if err != nil {
+		fmt.Println(err)
+		fmt.Printf("File parsing failed. Exiting program. \n")
+		// Go is garbaged collected
+		os.Exit(2)
+	}
+}
+
+// Print the store in the output format, bailing if it's not one
+func printStore() {
+	str, err := acls.Format(*outFormatFlag)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+
+	// Text gets a blank line after it, like it always has
+	if *outFormatFlag == acl.F_TEXT {
+		str += "\n"
+	}
+	fmt.Print(str)
+}
+
+// Write the store to the -o file, or back over the ACL file with -i,
+// in the format saveFormat says
+func saveStore() {
+	filename := *outFileFlag
+	if *inPlaceFlag {
+		filename = flag.Arg(0)
+	}
+	if filename == "" {
+		return
+	}
+
+	if err := acls.WriteFile(filename, saveFormat()); err != nil {
+		fmt.Println(err)
+		fmt.Printf("Saving failed. Exiting program. \n")
+		os.Exit(2)
+	}
+
+	fmt.Fprintf(msgs, "Access control lists written to %s\n", filename)
+
+	// Keep the signature good, or the next run won't take the file
+	if key != nil {
+		if _, err := acl.SignFile(filename, saveFormat(), key); err != nil {
+			fmt.Println(err)
+			fmt.Printf("Signing failed. Exiting program. \n")
+			os.Exit(2)
 		}
+		fmt.Fprintf(msgs, "Signature written to %s%s\n", filename, acl.SigSuffix)
 	}
+}
 
-	// return true
+// The format files are written in: the output format, unless that's
+// text, which can't be read back in; then the input format.
+func saveFormat() (format string) {
+	format = *outFormatFlag
+	if format == acl.F_TEXT {
+		format = *inFormatFlag
+	}
+	if format == acl.F_TEXT {
+		format = acl.F_ACL
+	}
+	return
 }
 
-/* Function: parseCommandFile()
- * Parameters: 1. filename: The name of the file to be parsed.
- *             2. listptr:  The address of the pointer to the access control
- *                          list that will be altered as a result of
- *                          parsing the file.
- *
- * Description: This function reads an input file and alters an access
- *              control list based on the contents of the file.  The
- *              possible commands are:
- *
- *    dr: Delete Right.
- *    ar: Add Right.
- *    de: Delete Entry.
- *
- *  For example, if the file reads,
- *
- *  dr
- *  vegdahl
- *  4
- *
- *  Then the access control list should be altered so that the user
- *  'vegdahl' no longer has the right to 'write' to the file.  See
- *  aclist.h for a mapping from integers to rights.
- *
- */
-func parseCommandFile(filename string) (err error) {
-
-	// attempt to open file
-	file, err := os.Open(filename)
+// Set the modes of the files in the -apply directory from a store,
+// or with -dry-run, just say what would change
+func applyStore(store *acl.Store) {
+	if *applyFlag == "" {
+		return
+	}
 
+	plan, err := store.PlanModes(*applyFlag)
 	if err != nil {
-		return err
-	}
-	defer file.Close()
-	fmt.Printf("%s was successfully opened.\nParsing commands from file.\n", filename)
-
-	var d right
-	var username string
-	var cmd string
-
-	// File was opened, so begin parsing
-	for {
-		_, err := fmt.Fscanf(file, "%s\n", &cmd)
-		// If EOF, be done, otherwise there was an error
-		switch {
-		case err == io.EOF:
-			return nil
-		case err != nil:
-			return err
-		}
-
-		// otherwise, this *should* be a command
-		switch cmd {
-		// Deleting or adding rights
-		case "dr", "ar":
-			// Next word is username
-			if _, err := fmt.Fscanf(file, "%s\n", &username); err != nil {
-				return err
-			}
-
-			// and the next is the right to add/delete
-			if _, err := fmt.Fscanf(file, "%d\n", &d); err != nil {
-				return err
-			}
-
-			// add or delete as needed
-			switch cmd {
-			case "dr":
-				fmt.Printf("Delete right")
-				acl.deleteRight(d, username)
-			case "ar":
-				fmt.Printf("Add right")
-				acl.addRight(d, username)
-			}
-
-			// And add some pretty info text
-			fmt.Printf(" = %d on user %s \n", d, username)
-
-		// Deleting a whole entry
-		case "de":
-			// Next word is username
-			if _, err := fmt.Fscanf(file, "%s\n", &username); err != nil {
-				return err
-			}
-
-			fmt.Printf("Delete user %s \n", username)
-			acl.deleteEntry(username)
+		fmt.Println(err)
+		fmt.Printf("Reading directory failed. Exiting program. \n")
+		os.Exit(2)
+	}
+
+	if *dryRunFlag {
+		fmt.Printf("Dry run, applying to %s would make these changes:\n", *applyFlag)
+		plan.Print()
+		return
+	}
+
+	fmt.Printf("Applying to %s:\n", *applyFlag)
+	plan.Print()
+	if err := plan.Apply(); err != nil {
+		fmt.Println(err)
+		fmt.Printf("Changing modes failed. Exiting program. \n")
+		os.Exit(2)
+	}
+}
+
+// Print messages about what's going on to w, ours and the package's
+func setMessages(w io.Writer) {
+	msgs = w
+	acl.SetMessages(w)
+}
+
+func paramsCheck() {
+	flag.Parse()
+
+	if flag.NArg() < 1 || (flag.NArg() > 2 && !*lintFlag && !reporting()) || (*diffFlag && flag.NArg() != 2) ||
+		((*serveFlag != "" || *shellFlag) && flag.NArg() != 1) {
+		fmt.Printf("%s error: incorrect number of parameters.\n"+
+			"usage: %s [-key <keyFile>] [-verify off|warn|strict] [-rights <rightsFile>] [-roles <rolesFile>] [-g <groupFile>] "+
+			"[-passwd <passwdFile>] [-etc-group <groupFile>] [-unknown-users reject|warn] [-if acl|json|yaml|facl|dir] [-of text|acl|json|yaml|facl] "+
+			"[-o <outFile> | -i] [-apply <dir>] [-dry-run] [-audit <logFile>] [-as <user>] [-max-owners <n>] [-now <time>]\n"+
+			"       <aclFile> [<commandFile>]\n"+
+			"       %s -serve <addr> [-rights <rightsFile>] [-roles <rolesFile>] [-g <groupFile>] [-if acl|json|yaml|facl|dir] <aclFile>\n"+
+			"       %s -shell [-rights <rightsFile>] [-roles <rolesFile>] [-g <groupFile>] [-if acl|json|yaml|facl] [-of acl|json|yaml|facl] "+
+			"[-o <outFile>] [-audit <logFile>] [-as <user>] <aclFile>\n"+
+			"       %s -lint [-lint-rules <rulesFile>] [-rights <rightsFile>] [-roles <rolesFile>] [-g <groupFile>] [-max-owners <n>] [-of text|json] "+
+			"<aclFile> ...\n"+
+			"       %s -sign [-key <keyFile>] [-if acl|json|yaml|facl] <aclFile>\n"+
+			"       %s -matrix [-of text|csv] | -files-for <user> [-right <rights>] | -users-on <file> [-right <rights>]\n"+
+			"          [-rights <rightsFile>] [-roles <rolesFile>] [-g <groupFile>] [-if acl|json|yaml|facl|dir] [-now <time>] <aclFile> ...\n"+
+			"       %s -diff [-diff-cmds] [-rights <rightsFile>] [-roles <rolesFile>] [-if acl|json|yaml|facl] <aclFile> <aclFile>\n",
+			os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0])
+
+		os.Exit(2)
+	}
+
+	if *inPlaceFlag && *outFileFlag != "" {
+		fmt.Printf("%s error: -o and -i can't be used together.\n", os.Args[0])
+		os.Exit(2)
+	}
+
+	var err error
+	if key, err = acl.ReadKey(*keyFlag); err != nil {
+		fmt.Printf("%s error: -key: %v.\n", os.Args[0], err)
+		os.Exit(2)
+	}
+
+	switch *verifyFlag {
+	case "":
+		*verifyFlag = "off"
+		if key != nil {
+			*verifyFlag = "strict"
+		}
+	case "off":
+	case "warn", "strict":
+		if key == nil {
+			fmt.Printf("%s error: -verify %s needs a key, from -key or $%s.\n", os.Args[0], *verifyFlag, acl.KeyEnv)
+			os.Exit(2)
+		}
+	default:
+		fmt.Printf("%s error: -verify is off, warn or strict, not %s.\n", os.Args[0], *verifyFlag)
+		os.Exit(2)
+	}
+
+	if *signFlag && (key == nil || flag.NArg() != 1) {
+		fmt.Printf("%s error: -sign needs a key, from -key or $%s, and just the ACL file.\n", os.Args[0], acl.KeyEnv)
+		os.Exit(2)
+	}
+
+	if *unknownFlag != "reject" && *unknownFlag != "warn" {
+		fmt.Printf("%s error: -unknown-users is reject or warn, not %s.\n", os.Args[0], *unknownFlag)
+		os.Exit(2)
+	}
+
+	if *nowFlag != "" {
+		t, err := acl.ParseTime(*nowFlag)
+		if err != nil {
+			fmt.Printf("%s error: -now: %v.\n", os.Args[0], err)
+			os.Exit(2)
 		}
+		acl.SetNow(t)
 	}
 }